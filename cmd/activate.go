@@ -1,28 +1,37 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	activateEval bool // 是否输出 eval 格式 / Whether to output eval format
+	activateEval   bool   // 是否输出 eval 格式 / Whether to output eval format
+	activateShell  string // eval 输出的目标 shell / Shell the eval output is meant for
+	activateLocal  bool   // 只影响当前 shell / Only affect the current shell
+	activateGlobal bool   // 只修改默认环境 / Only change the default environment
 )
 
 var activateCmd = &cobra.Command{
 	Use:   "activate [env-name]",
 	Short: "Activates a provider environment.",
-	Long: `Activates a specified provider environment by updating the active environment script.
+	Long: `Activates a specified provider environment in the current shell and makes it the
+default environment, which new shells start with.
+
+  --local   only activates it in the current shell
+  --global  only makes it the default (same as 'cc-provider default set')
+
+What a plain activate does can be changed with 'cc-provider config set activate-scope'.
 
 For immediate activation in current shell, use:
   eval "$(cc-provider activate --eval <env-name>)"
 
-Otherwise, restart your shell or source your shell config file to apply changes.`,
+Use --shell to get the --eval output for another shell, e.g. fish, pwsh or nu.
+
+The shell function installed by setup applies the activation without --eval.`,
 	Args:              cobra.ExactArgs(1),
 	ValidArgsFunction: completeEnvironmentNames,
 	Run:               runActivateCmd,
@@ -30,32 +39,85 @@ Otherwise, restart your shell or source your shell config file to apply changes.
 
 func runActivateCmd(cmd *cobra.Command, args []string) {
 	envName := args[0]
-	envFilePath := filepath.Join(cfgDir, envName)
+	mustValidateEnvName(envName)
+	if _, ok := shellDialects[activateShell]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: Unsupported shell '%s' (supported: %s).\n", activateShell, strings.Join(shellDialectNames(), ", "))
+		os.Exit(1)
+	}
+	scope, err := resolveActivationScope(activateLocal, activateGlobal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	reg := mustLoadRegistry()
 
 	// 1. Validate environment exists
-	if _, err := os.Stat(envFilePath); os.IsNotExist(err) {
+	if !reg.Has(envName) {
 		fmt.Fprintf(os.Stderr, "Error: Environment '%s' not found.\n", envName)
 		os.Exit(1)
 	}
 
-	// 2. Generate and write active_env.sh
-	if err := writeActiveEnvScript(envName, envFilePath); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing active environment script: %v\n", err)
+	// 2. Resolve the environment for the current shell. The default scripts
+	// are built separately, as they keep secret references unresolved.
+	var script *activationScript
+	if scope != scopeGlobal {
+		if script, err = loadActivationScript(envName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading environment: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// 3. Unless only the current shell is affected, generate and write active_env.sh
+	unlock := mustLockConfig()
+	defer unlock()
+
+	if scope != scopeLocal {
+		if err := setDefaultEnv(envName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if err := updateRegistry(func(r *Registry) { r.MarkActivated(envName) }); err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating environment registry: %v\n", err)
 		os.Exit(1)
 	}
 
-	// 3. If --eval flag is set, output shell commands for immediate activation
+	// 4. If --eval flag is set, output shell commands for immediate activation
 	// 如果设置了 --eval 标志,输出 shell 命令以立即激活
 	if activateEval {
-		if err := outputEvalCommands(envName, envFilePath); err != nil {
-			fmt.Fprintf(os.Stderr, "Error generating eval commands: %v\n", err)
-			os.Exit(1)
+		if scope == scopeGlobal {
+			// Leave the current shell alone and only report the new default
+			script = &activationScript{message: fmt.Sprintf("Default environment set to '%s'.", envName)}
 		}
+		// Output the commands to stdout for eval
+		// 将命令输出到 stdout 供 eval 使用
+		fmt.Print(shellDialects[activateShell].eval(script))
 		return
 	}
 
-	// 4. Normal mode: update config file and prompt user
+	// 5. Run through the shell function, the current shell is updated right away
+	if scope != scopeGlobal {
+		applied, err := applyToShell(script)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if applied {
+			return
+		}
+	}
+
+	// 6. Normal mode: update config file and prompt user
 	// 普通模式:更新配置文件并提示用户
+	switch scope {
+	case scopeGlobal:
+		fmt.Printf("Default environment set to '%s'. It will be active in new shell sessions.\n", envName)
+		return
+	case scopeLocal:
+		fmt.Println("A local activation only changes the current shell. Run:")
+		fmt.Printf("  cc-provider activate --local %s\n", envName)
+		fmt.Printf("\n(If the shell function is not loaded, use: eval \"$(command cc-provider activate --local --eval %s)\")\n", envName)
+		return
+	}
 	fmt.Printf("Successfully updated environment '%s' configuration.\n", envName)
 	fmt.Println("\nThe environment will be active in new shell sessions.")
 	fmt.Println("To activate immediately in current shell, run:")
@@ -63,86 +125,79 @@ func runActivateCmd(cmd *cobra.Command, args []string) {
 	fmt.Printf("\n(If the shell function is not loaded, use: eval \"$(command cc-provider activate --eval %s)\")\n", envName)
 }
 
-// outputEvalCommands outputs shell commands for immediate activation via eval
-// 输出用于通过 eval 立即激活的 shell 命令
-func outputEvalCommands(envName, envFilePath string) error {
-	var sb strings.Builder
-
-	// Unset previous variables
-	// 取消设置之前的变量
-	for _, key := range envVarKeys {
-		sb.WriteString(fmt.Sprintf("unset %s; ", key))
+// setDefaultEnv makes envName the default: the active environment scripts
+// are rewritten and the registry records it. The caller must hold the config
+// lock.
+func setDefaultEnv(envName string) error {
+	script, err := loadActiveEnvScript(envName)
+	if err != nil {
+		return err
 	}
-
-	// Read variables from the environment file
-	// 从环境文件读取变量
-	content, err := os.ReadFile(envFilePath)
+	if err := writeActiveEnvScript(script); err != nil {
+		return fmt.Errorf("writing active environment script: %w", err)
+	}
+	err = updateRegistry(func(r *Registry) {
+		r.MarkActivated(envName)
+		r.SetActive(envName)
+	})
 	if err != nil {
-		return fmt.Errorf("reading env file %s: %w", envFilePath, err)
-	}
-
-	// Export new environment variables
-	// 导出新的环境变量
-	scanner := bufio.NewScanner(strings.NewReader(string(content)))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
-			key := parts[0]
-			value := parts[1]
-			sb.WriteString(fmt.Sprintf("export %s=%s; ", key, value))
-		}
+		return fmt.Errorf("updating environment registry: %w", err)
 	}
+	return nil
+}
 
-	// Set the active environment identifier
-	// 设置激活的环境标识符
-	sb.WriteString(fmt.Sprintf(`export CC_PROVIDER_ACTIVE_ENV="%s"; `, envName))
-
-	// Output success message to stderr so it doesn't interfere with eval
-	// 将成功消息输出到 stderr,以免干扰 eval
-	sb.WriteString(fmt.Sprintf(`echo "Environment '%s' activated." >&2`, envName))
-
-	// Output the commands to stdout for eval
-	// 将命令输出到 stdout 供 eval 使用
-	fmt.Print(sb.String())
+// writeActiveEnvScript writes the activation to the active_env.sh file and
+// its counterparts for the other shell dialects.
+func writeActiveEnvScript(script *activationScript) error {
+	for _, d := range activeScriptDialects {
+		if err := writeFileAtomic(activeEnvScriptPath(d), []byte(d.file(script)), 0600); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// writeActiveEnvScript generates the content for and writes to the active_env.sh file.
-func writeActiveEnvScript(envName, envFilePath string) error {
-	var sb strings.Builder
+// loadActivationScript resolves the environment, with the environments it
+// extends, and builds its activation.
+// 解析环境(包括继承的环境)并构建激活脚本
+func loadActivationScript(envName string) (*activationScript, error) {
+	resolved, err := resolveEnvironment(envName)
+	if err != nil {
+		return nil, err
+	}
 
-	// Always start by unsetting all managed keys to ensure a clean state.
-	sb.WriteString("# Unset previous variables managed by cc-provider\n")
-	for _, key := range envVarKeys {
-		sb.WriteString(fmt.Sprintf("unset %s\n", key))
+	vars, err := resolveVars(resolved.vars)
+	if err != nil {
+		return nil, fmt.Errorf("environment '%s': %w", envName, err)
 	}
-	sb.WriteString("\n")
 
-	// Read variables from the environment file
-	content, err := os.ReadFile(envFilePath)
+	script, err := newActivationScript(envName, vars)
 	if err != nil {
-		return fmt.Errorf("reading env file %s: %w", envFilePath, err)
-	}
-
-	// Add export commands for the new environment
-	sb.WriteString(fmt.Sprintf("# Export variables for environment: %s\n", envName))
-	scanner := bufio.NewScanner(strings.NewReader(string(content)))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
-			key := parts[0]
-			value := parts[1]
-			sb.WriteString(fmt.Sprintf("export %s=%s\n", key, value))
-		}
+		return nil, fmt.Errorf("environment '%s': %w", envName, err)
 	}
-	sb.WriteString("\n")
+	return script, nil
+}
 
-	// Set the active environment identifier
-	sb.WriteString(fmt.Sprintf(`export CC_PROVIDER_ACTIVE_ENV="%s"`, envName))
-	sb.WriteString("\n")
+// loadActiveEnvScript builds the activation of envName for the active
+// environment scripts. Secret references are left unresolved: the scripts
+// resolve them when a shell starts, so no secret is copied to disk.
+// 构建默认环境脚本:密钥引用在 shell 启动时解析,不写入磁盘
+func loadActiveEnvScript(envName string) (*activationScript, error) {
+	resolved, err := resolveEnvironment(envName)
+	if err != nil {
+		return nil, err
+	}
 
-	// Write the generated script to the active_env.sh file.
-	return os.WriteFile(activeEnvFile, []byte(sb.String()), 0644)
+	script, err := newActivationScript(envName, resolved.vars)
+	if err != nil {
+		return nil, fmt.Errorf("environment '%s': %w", envName, err)
+	}
+	for _, v := range script.vars {
+		if isSecretRef(v.Value) {
+			script.deferred = append(script.deferred, v.Key)
+		}
+	}
+	return script, nil
 }
 
 // completeEnvironmentNames provides completion for environment names
@@ -157,4 +212,9 @@ func completeEnvironmentNames(cmd *cobra.Command, args []string, toComplete stri
 func init() {
 	rootCmd.AddCommand(activateCmd)
 	activateCmd.Flags().BoolVarP(&activateEval, "eval", "e", false, "Output shell commands for eval (use with: eval \"$(cc-provider activate --eval <env>)\")")
+	activateCmd.Flags().BoolVar(&activateLocal, "local", false, "Only activate in the current shell, keeping the default environment")
+	activateCmd.Flags().BoolVar(&activateGlobal, "global", false, "Only change the default environment, keeping the current shell")
+	activateCmd.MarkFlagsMutuallyExclusive("local", "global")
+	activateCmd.Flags().StringVar(&activateShell, "shell", "bash", "Shell the --eval output is meant for ("+strings.Join(shellDialectNames(), ", ")+")")
+	activateCmd.RegisterFlagCompletionFunc("shell", cobra.FixedCompletions(shellDialectNames(), cobra.ShellCompDirectiveNoFileComp))
 }
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+var cloneSet []string // KEY=VALUE 形式的变量 / Variables as KEY=VALUE
+
+// cloneCmd represents the clone command
+var cloneCmd = &cobra.Command{
+	Use:   "clone <source> <new-name>",
+	Short: "Creates a provider environment as a copy of another.",
+	Long: `Creates a provider environment as a copy of another, including its token and metadata.
+Use --set to change variables of the copy, for quick variants:
+
+  cc-provider clone deepseek deepseek-fast --set ANTHROPIC_MODEL=deepseek-chat
+
+To keep following changes of the source instead, use 'cc-provider create <new-name> --extends <source>'.`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeSourceEnvironment,
+	Run:               runCloneCmd,
+}
+
+func runCloneCmd(cmd *cobra.Command, args []string) {
+	srcName, newName := args[0], args[1]
+	mustValidateEnvName(srcName)
+	mustValidateEnvName(newName)
+	assignments := mustParseAssignments(cloneSet)
+	v := mustOpenVaultIf(vaultNeededFor(srcName, assignments, srcName))
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	doc := mustLoadEnvDocument(srcName)
+	mustBeNewEnvironment(newName)
+	for _, v := range assignments {
+		doc.Set(v.Key, v.Value)
+	}
+	mustHaveRequiredKeys(newName, doc)
+
+	// The copy gets its own vault entries, so changing a secret of either
+	// environment leaves the other alone
+	v, _, err := copyVaultSecrets(v, srcName, newName, doc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error copying secrets in vault: %v\n", err)
+		os.Exit(1)
+	}
+	if _, _, err := storeSecretsInVault(v, newName, doc); err != nil {
+		fmt.Fprintf(os.Stderr, "Error storing secrets in vault: %v\n", err)
+		os.Exit(1)
+	}
+
+	envFilePath := environmentPath(newName)
+	if err := writeEnvDocument(envFilePath, doc); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing environment file '%s': %v\n", envFilePath, err)
+		os.Exit(1)
+	}
+
+	err = updateRegistry(func(r *Registry) {
+		src := r.Get(srcName)
+		r.Add(newName, &EnvMeta{
+			Description: src.Description,
+			Template:    src.Template,
+			Tags:        slices.Clone(src.Tags),
+		})
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating environment registry: %v\n", err)
+		os.Exit(1)
+	}
+	recordHistory(envHistory, newName, "clone of "+srcName)
+
+	fmt.Printf("Successfully cloned environment '%s' to '%s'.\n", srcName, newName)
+	fmt.Printf("To activate it, run: cc-provider activate %s\n", newName)
+}
+
+func init() {
+	rootCmd.AddCommand(cloneCmd)
+	cloneCmd.Flags().StringArrayVar(&cloneSet, "set", nil, "Set a variable of the copy, as KEY=VALUE (repeatable)")
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// settingKey describes a setting that can be read and changed with 'config'.
+type settingKey struct {
+	name        string
+	description string
+	values      []string // the accepted values, for completion
+	get         func(s *Settings) string
+	set         func(s *Settings, value string) error
+}
+
+// settingKeys are the settings known to 'config', in display order.
+var settingKeys = []settingKey{
+	{
+		name:        "activate-scope",
+		description: "what a plain activate or deactivate changes: both, local (current shell) or global (default)",
+		values:      []string{string(scopeBoth), string(scopeLocal), string(scopeGlobal)},
+		get: func(s *Settings) string {
+			if s.ActivateScope == "" {
+				return string(scopeBoth)
+			}
+			return string(s.ActivateScope)
+		},
+		set: func(s *Settings, value string) error {
+			scope, err := parseActivationScope(value)
+			s.ActivateScope = scope
+			return err
+		},
+	},
+	{
+		name:        "project-hook",
+		description: "switch environments automatically from .cc-provider project files (see setup --hook)",
+		values:      []string{"true", "false"},
+		get:         func(s *Settings) string { return strconv.FormatBool(s.ProjectHook) },
+		set: func(s *Settings, value string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value '%s' (valid: true, false)", value)
+			}
+			s.ProjectHook = enabled
+			return nil
+		},
+	},
+}
+
+// findSettingKey returns the setting called name.
+func findSettingKey(name string) (settingKey, bool) {
+	for _, key := range settingKeys {
+		if key.name == name {
+			return key, true
+		}
+	}
+	return settingKey{}, false
+}
+
+var configCmd = &cobra.Command{
+	Use:         "config",
+	Short:       "Show or change settings.",
+	Long:        `Show or change the settings kept in config.json in the config directory.`,
+	Annotations: map[string]string{readOnlyAnnotation: "true"},
+	Run:         runConfigListCmd,
+}
+
+var configListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List all settings.",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{readOnlyAnnotation: "true"},
+	Run:         runConfigListCmd,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:               "get <key>",
+	Short:             "Print a setting.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSettingArgs,
+	Annotations:       map[string]string{readOnlyAnnotation: "true"},
+	Run:               runConfigGetCmd,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:               "set <key> <value>",
+	Short:             "Change a setting.",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeSettingArgs,
+	Run:               runConfigSetCmd,
+}
+
+func runConfigListCmd(cmd *cobra.Command, args []string) {
+	settings := mustLoadSettings()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tDESCRIPTION")
+	for _, key := range settingKeys {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key.name, key.get(settings), key.description)
+	}
+	w.Flush()
+}
+
+func runConfigGetCmd(cmd *cobra.Command, args []string) {
+	key := mustFindSettingKey(args[0])
+	fmt.Println(key.get(mustLoadSettings()))
+}
+
+func runConfigSetCmd(cmd *cobra.Command, args []string) {
+	key := mustFindSettingKey(args[0])
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	settings := mustLoadSettings()
+	if err := key.set(settings, args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", key.name, err)
+		os.Exit(1)
+	}
+	if err := settings.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving settings: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Set %s to %s.\n", key.name, key.get(settings))
+
+	// The shell integration depends on the project hook setting
+	if key.name == "project-hook" {
+		if _, err := ensureShellConfig(true); err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating shell integration: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Please restart your shell or source your config file to apply changes.")
+	}
+}
+
+// mustLoadSettings loads the settings or exits with an error.
+func mustLoadSettings() *Settings {
+	settings, err := loadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading settings: %v\n", err)
+		os.Exit(1)
+	}
+	return settings
+}
+
+// mustFindSettingKey returns the setting called name or exits with an error.
+func mustFindSettingKey(name string) settingKey {
+	key, ok := findSettingKey(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: Unknown setting '%s'. Run 'cc-provider config list' to see all settings.\n", name)
+		os.Exit(1)
+	}
+	return key
+}
+
+// completeSettingArgs completes setting names, then their values.
+func completeSettingArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		var names []string
+		for _, key := range settingKeys {
+			names = append(names, key.name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+	if key, ok := findSettingKey(args[0]); ok && len(args) == 1 && cmd.Name() == "set" {
+		return key.values, cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configListCmd, configGetCmd, configSetCmd)
+}
@@ -4,145 +4,258 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	createDescription string   // 环境描述 / Environment description
+	createTags        []string // 环境标签 / Environment tags
+	createExtends     string   // 基础环境 / Base environment
+	createTemplate    string   // 使用的模板 / Template to start from
+	createSet         []string // KEY=VALUE 形式的变量 / Variables as KEY=VALUE
+	createTokenStdin  bool     // 从标准输入读取令牌 / Read the auth token from stdin
+	createYes         bool     // 不提示,使用默认值 / Do not prompt, use defaults
+)
+
 // createCmd represents the create command
 var createCmd = &cobra.Command{
-	Use:   "create",
+	Use:   "create [env-name]",
 	Short: "Interactively creates a new provider environment.",
-	Long:  `Interactively prompts for the necessary details to create a new provider environment file in the ~/.cc-provider directory.`,
-	Run:   runCreateCmd,
+	Long: `Interactively prompts for the necessary details to create a new provider environment file in the config directory.
+
+Values can also be given on the command line, which skips the prompts:
+
+  cc-provider create deepseek --template deepseek --token-stdin < token.txt
+  cc-provider create work --set ANTHROPIC_BASE_URL=https://... --set ANTHROPIC_AUTH_TOKEN=cmd:pass show work
+
+Without a terminal, or with --yes, nothing is asked either: optional variables get
+their defaults, and missing required variables are an error.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: cobra.NoFileCompletions,
+	Run:               runCreateCmd,
 }
 
 func runCreateCmd(cmd *cobra.Command, args []string) {
 	reader := bufio.NewReader(os.Stdin)
+	interactive := shouldPrompt(createYes, len(createSet) > 0 || createTokenStdin)
+	assignments := mustParseAssignments(createSet)
 
-	// 1. Prompt for environment name
-	envName := prompt(reader, "Enter environment name (e.g., 'deepseek')", true)
-	envFilePath := filepath.Join(cfgDir, envName)
+	// 1. Get the environment name
+	var envName string
+	switch {
+	case len(args) > 0:
+		envName = args[0]
+		mustValidateEnvName(envName)
+	case interactive:
+		envName = prompt(reader, "Enter environment name (e.g., 'deepseek')", true)
+		for validateEnvName(envName) != nil {
+			fmt.Println(validateEnvName(envName))
+			envName = prompt(reader, "Enter environment name (e.g., 'deepseek')", true)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "Error: An environment name is required: cc-provider create <env-name>")
+		os.Exit(1)
+	}
+	envFilePath := environmentPath(envName)
 
 	if _, err := os.Stat(envFilePath); !os.IsNotExist(err) {
 		fmt.Fprintf(os.Stderr, "Error: Environment '%s' already exists.\n", envName)
 		os.Exit(1)
 	}
 
-	// 2. Ask if user wants to use a template
-	fmt.Println("\nWould you like to use a template? (y/n)")
-	useTemplate := prompt(reader, "Use template", false)
-	var envVars map[string]string
+	// An environment extending another one starts with the values of its base
+	if createExtends != "" {
+		mustValidateEnvName(createExtends)
+		if !mustLoadRegistry().Has(createExtends) {
+			fmt.Fprintf(os.Stderr, "Error: Environment '%s' not found.\n", createExtends)
+			os.Exit(1)
+		}
+	}
+	inherited, err := inheritedValues(createExtends)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving environment '%s': %v\n", createExtends, err)
+		os.Exit(1)
+	}
+	envVars := make(map[string]string)
+	for k, v := range inherited {
+		envVars[k] = v
+	}
+
+	meta := &EnvMeta{Description: createDescription, Tags: createTags}
+	if meta.Description == "" && interactive {
+		meta.Description = prompt(reader, "Enter description (optional)", false)
+	}
 
-	if strings.ToLower(useTemplate) == "y" || strings.ToLower(useTemplate) == "yes" {
-		// List available templates
-		templates, err := listTemplates()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error listing templates: %v\n", err)
+	// 2. Start from a template: the one given with --template, or ask if the
+	// user wants one, unless the base provides the values
+	var tmpl *Template
+	switch {
+	case createTemplate != "":
+		mustValidateTemplateName(createTemplate)
+		if tmpl, err = getTemplate(createTemplate); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+	case interactive && createExtends == "":
+		tmpl = selectTemplate(reader)
+	}
+	if tmpl != nil {
+		for k, v := range tmpl.EnvVars {
+			envVars[k] = v
+		}
+		meta.Template = tmpl.Name
+		if interactive {
+			fmt.Printf("\nUsing template '%s'.\n", tmpl.Name)
+		}
+	}
 
-		if len(templates) == 0 {
-			fmt.Println("No templates available. Creating environment manually.")
-			envVars = make(map[string]string)
-		} else {
-			fmt.Println("\nAvailable templates:")
-			for i, tmpl := range templates {
-				fmt.Printf("  %d. %s - %s\n", i+1, tmpl.Name, tmpl.Description)
-			}
+	// 3. Values given on the command line
+	for _, v := range assignments {
+		envVars[v.Key] = v.Value
+	}
+	if createTokenStdin {
+		envVars["ANTHROPIC_AUTH_TOKEN"] = mustReadTokenStdin()
+	}
 
-			selection := prompt(reader, "\nSelect template number (or press Enter to skip)", false)
-			if selection != "" {
-				var idx int
-				if _, err := fmt.Sscanf(selection, "%d", &idx); err == nil && idx > 0 && idx <= len(templates) {
-					selectedTmpl := templates[idx-1]
-					envVars = make(map[string]string)
-					for k, v := range selectedTmpl.EnvVars {
-						envVars[k] = v
-					}
-					fmt.Printf("\nUsing template '%s'.\n", selectedTmpl.Name)
-				} else {
-					fmt.Println("Invalid selection. Creating environment manually.")
-					envVars = make(map[string]string)
-				}
-			} else {
-				envVars = make(map[string]string)
+	if interactive {
+		promptEnvVars(reader, envVars)
+	} else {
+		// Optional variables get their defaults, as when Enter is pressed
+		for _, opt := range optionalEnvDefaults {
+			if envVars[opt.Key] == "" {
+				envVars[opt.Key] = opt.Value
 			}
 		}
-	} else {
-		envVars = make(map[string]string)
+		if missing := missingRequiredKeys(envVars); len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: Missing required variables: %s. Pass them with --set KEY=VALUE or --token-stdin.\n", strings.Join(missing, ", "))
+			os.Exit(1)
+		}
 	}
 
-	// 3. Required inputs
-	fmt.Println("\nEnter required variables: ")
-	if baseURL := promptWithExisting(reader, "  ANTHROPIC_BASE_URL", envVars["ANTHROPIC_BASE_URL"], true); baseURL != "" {
-		envVars["ANTHROPIC_BASE_URL"] = baseURL
+	// 4. Write to file in canonical key order, keeping only what differs from the base
+	doc := NewEnvFile()
+	if createExtends != "" {
+		doc.Set(extendsKey, createExtends)
 	}
-	if authToken := promptWithExisting(reader, "  ANTHROPIC_AUTH_TOKEN", envVars["ANTHROPIC_AUTH_TOKEN"], true); authToken != "" {
-		envVars["ANTHROPIC_AUTH_TOKEN"] = authToken
+	for key, value := range envVars {
+		if inheritedValue, ok := inherited[key]; !ok || value != inheritedValue {
+			doc.Set(key, value)
+		}
 	}
+	migrateEnvDocument(doc)
+	v := mustOpenVaultIf(vaultNeeded(doc, ""))
 
-	// 4. Recommended inputs
-	fmt.Println("\nEnter recommended variables (press Enter to skip): ")
-	if model := promptWithExisting(reader, "  ANTHROPIC_MODEL", envVars["ANTHROPIC_MODEL"], false); model != "" {
-		envVars["ANTHROPIC_MODEL"] = model
+	unlock := mustLockConfig()
+	defer unlock()
+
+	// Check again under the lock in case another process created it meanwhile
+	if _, err := os.Stat(envFilePath); !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error: Environment '%s' already exists.\n", envName)
+		os.Exit(1)
 	}
-	if haikuModel := promptWithExisting(reader, "  ANTHROPIC_DEFAULT_HAIKU_MODEL", envVars["ANTHROPIC_DEFAULT_HAIKU_MODEL"], false); haikuModel != "" {
-		envVars["ANTHROPIC_DEFAULT_HAIKU_MODEL"] = haikuModel
+
+	// Keep secrets in the vault if one has been initialized
+	if _, _, err := storeSecretsInVault(v, envName, doc); err != nil {
+		fmt.Fprintf(os.Stderr, "Error storing secrets in vault: %v\n", err)
+		os.Exit(1)
 	}
-	if sonnetModel := promptWithExisting(reader, "  ANTHROPIC_DEFAULT_SONNET_MODEL", envVars["ANTHROPIC_DEFAULT_SONNET_MODEL"], false); sonnetModel != "" {
-		envVars["ANTHROPIC_DEFAULT_SONNET_MODEL"] = sonnetModel
+
+	if err := writeEnvDocument(envFilePath, doc); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing environment file '%s': %v\n", envFilePath, err)
+		os.Exit(1)
 	}
-	if opusModel := promptWithExisting(reader, "  ANTHROPIC_DEFAULT_OPUS_MODEL", envVars["ANTHROPIC_DEFAULT_OPUS_MODEL"], false); opusModel != "" {
-		envVars["ANTHROPIC_DEFAULT_OPUS_MODEL"] = opusModel
+
+	if err := updateRegistry(func(r *Registry) { r.Add(envName, meta) }); err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating environment registry: %v\n", err)
+		os.Exit(1)
 	}
-	if subagentModel := promptWithExisting(reader, "  CLAUDE_CODE_SUBAGENT_MODEL", envVars["CLAUDE_CODE_SUBAGENT_MODEL"], false); subagentModel != "" {
-		envVars["CLAUDE_CODE_SUBAGENT_MODEL"] = subagentModel
+	recordHistory(envHistory, envName, "create")
+
+	fmt.Printf("\nSuccessfully created environment '%s'.\n", envName)
+	fmt.Printf("To activate it, run: cc-provider activate %s\n", envName)
+}
+
+// selectTemplate asks whether to use a template and which one. It returns
+// nil if none was chosen.
+func selectTemplate(reader *bufio.Reader) *Template {
+	fmt.Println("\nWould you like to use a template? (y/n)")
+	useTemplate := prompt(reader, "Use template", false)
+	if strings.ToLower(useTemplate) != "y" && strings.ToLower(useTemplate) != "yes" {
+		return nil
 	}
-	if effortLevel := promptWithExisting(reader, "  CLAUDE_CODE_EFFORT_LEVEL", envVars["CLAUDE_CODE_EFFORT_LEVEL"], false); effortLevel != "" {
-		envVars["CLAUDE_CODE_EFFORT_LEVEL"] = effortLevel
+
+	// List available templates
+	templates, err := listTemplates()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing templates: %v\n", err)
+		os.Exit(1)
+	}
+	if len(templates) == 0 {
+		fmt.Println("No templates available. Creating environment manually.")
+		return nil
 	}
 
-	// 5. Optional inputs with defaults
-	fmt.Println("\nEnter optional variables (press Enter to use default): ")
-	timeout := promptWithExisting(reader, "  API_TIMEOUT_MS", envVars["API_TIMEOUT_MS"], false)
-	if timeout == "" {
-		timeout = "600000"
+	fmt.Println("\nAvailable templates:")
+	for i, tmpl := range templates {
+		fmt.Printf("  %d. %s - %s\n", i+1, tmpl.Name, tmpl.Description)
 	}
-	envVars["API_TIMEOUT_MS"] = timeout
 
-	disableTraffic := promptWithExisting(reader, "  CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC", envVars["CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC"], false)
-	if disableTraffic == "" {
-		disableTraffic = "1"
+	selection := prompt(reader, "\nSelect template number (or press Enter to skip)", false)
+	if selection == "" {
+		return nil
+	}
+	var idx int
+	if _, err := fmt.Sscanf(selection, "%d", &idx); err != nil || idx <= 0 || idx > len(templates) {
+		fmt.Println("Invalid selection. Creating environment manually.")
+		return nil
 	}
-	envVars["CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC"] = disableTraffic
+	return &templates[idx-1]
+}
 
-	// 6. Write to file
-	var finalEnvVars []string
-	for key, value := range envVars {
-		finalEnvVars = append(finalEnvVars, fmt.Sprintf("%s=\"%s\"", key, value))
+// promptEnvVars asks for the required, recommended and optional variables,
+// offering the values already in envVars.
+func promptEnvVars(reader *bufio.Reader, envVars map[string]string) {
+	// Required inputs
+	fmt.Println("\nEnter required variables: ")
+	for _, key := range requiredEnvKeys {
+		if value := promptWithExisting(reader, "  "+key, envVars[key], true); value != "" {
+			envVars[key] = value
+		}
 	}
 
-	content := strings.Join(finalEnvVars, "\n")
-	if err := os.WriteFile(envFilePath, []byte(content), 0644); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing environment file '%s': %v\n", envFilePath, err)
-		os.Exit(1)
+	// Recommended inputs
+	fmt.Println("\nEnter recommended variables (press Enter to skip): ")
+	for _, key := range recommendedEnvKeys {
+		if value := promptWithExisting(reader, "  "+key, envVars[key], false); value != "" {
+			envVars[key] = value
+		}
 	}
 
-	fmt.Printf("\nSuccessfully created environment '%s'.\n", envName)
-	fmt.Printf("To activate it, run: cc-provider activate %s\n", envName)
+	// Optional inputs with defaults
+	fmt.Println("\nEnter optional variables (press Enter to use default): ")
+	for _, opt := range optionalEnvDefaults {
+		value := promptWithExisting(reader, "  "+opt.Key, envVars[opt.Key], false)
+		if value == "" {
+			value = opt.Value
+		}
+		envVars[opt.Key] = value
+	}
 }
 
-// prompt asks the user for input with a given message.
+// prompt asks the user for input with a given message. A required value
+// that cannot be read because the input has ended is an error.
 func prompt(reader *bufio.Reader, message string, required bool) string {
 	for {
 		fmt.Printf("%s: ", message)
-		input, _ := reader.ReadString('\n')
-		input = strings.TrimSpace(input)
+		input, ok := readInput(reader)
 		if input != "" || !required {
 			return input
 		}
+		if !ok {
+			exitInputEnded(message)
+		}
 		fmt.Println("This field is required.")
 	}
 }
@@ -150,8 +263,7 @@ func prompt(reader *bufio.Reader, message string, required bool) string {
 // promptWithDefault asks the user for input with a default value.
 func promptWithDefault(reader *bufio.Reader, message, defaultValue string) string {
 	fmt.Printf("%s (default: %s): ", message, defaultValue)
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(input)
+	input, _ := readInput(reader)
 	if input == "" {
 		return defaultValue
 	}
@@ -160,4 +272,13 @@ func promptWithDefault(reader *bufio.Reader, message, defaultValue string) strin
 
 func init() {
 	rootCmd.AddCommand(createCmd)
+	createCmd.Flags().StringVar(&createDescription, "description", "", "Description of the environment")
+	createCmd.Flags().StringSliceVar(&createTags, "tag", nil, "Tag to attach to the environment (repeatable)")
+	createCmd.Flags().StringVar(&createExtends, "extends", "", "Environment to inherit variables from; only the values that differ are stored")
+	createCmd.RegisterFlagCompletionFunc("extends", completeEnvironmentNamesForExport)
+	createCmd.Flags().StringVar(&createTemplate, "template", "", "Template to start from")
+	createCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+	createCmd.Flags().StringArrayVar(&createSet, "set", nil, "Set a variable, as KEY=VALUE (repeatable)")
+	createCmd.Flags().BoolVar(&createTokenStdin, "token-stdin", false, "Read ANTHROPIC_AUTH_TOKEN from stdin")
+	createCmd.Flags().BoolVarP(&createYes, "yes", "y", false, "Do not prompt; use defaults for optional variables")
 }
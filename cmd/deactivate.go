@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	deactivateEval   bool   // 是否输出 eval 格式 / Whether to output eval format
+	deactivateShell  string // eval 输出的目标 shell / Shell the eval output is meant for
+	deactivateLocal  bool   // 只影响当前 shell / Only affect the current shell
+	deactivateGlobal bool   // 只清除默认环境 / Only clear the default environment
+)
+
+var deactivateCmd = &cobra.Command{
+	Use:   "deactivate",
+	Short: "Deactivates the active provider environment.",
+	Long: `Deactivates the active provider environment. The variables managed by cc-provider
+are unset and no environment is active in new shell sessions. Other variables are left alone.
+
+  --local   only deactivates it in the current shell
+  --global  only clears the default environment (same as 'cc-provider default unset')
+
+For immediate deactivation in current shell, use:
+  eval "$(cc-provider deactivate --eval)"
+
+Use --shell to get the --eval output for another shell, e.g. fish, pwsh or nu.`,
+	Args: cobra.NoArgs,
+	Run:  runDeactivateCmd,
+}
+
+func runDeactivateCmd(cmd *cobra.Command, args []string) {
+	if _, ok := shellDialects[deactivateShell]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: Unsupported shell '%s' (supported: %s).\n", deactivateShell, strings.Join(shellDialectNames(), ", "))
+		os.Exit(1)
+	}
+	scope, err := resolveActivationScope(deactivateLocal, deactivateGlobal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	// The current shell knows which environment it has; the registry knows
+	// which one new shells get.
+	previous := os.Getenv("CC_PROVIDER_ACTIVE_ENV")
+	if previous == "" || scope == scopeGlobal {
+		previous = mustLoadRegistry().Active
+	}
+
+	script := newDeactivationScript(previous)
+	if scope != scopeLocal {
+		if script, err = deactivateActiveEnv(previous); err != nil {
+			fmt.Fprintf(os.Stderr, "Error deactivating environment: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if deactivateEval {
+		if scope == scopeGlobal {
+			// Leave the current shell alone and only report the change
+			script = &activationScript{message: "Default environment cleared."}
+		}
+		fmt.Print(shellDialects[deactivateShell].eval(script))
+		return
+	}
+
+	// Run through the shell function, the current shell is updated right away
+	if scope != scopeGlobal {
+		applied, err := applyToShell(script)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if applied {
+			return
+		}
+	}
+
+	switch scope {
+	case scopeGlobal:
+		fmt.Println("Default environment cleared. No environment will be active in new shell sessions.")
+		return
+	case scopeLocal:
+		fmt.Println("A local deactivation only changes the current shell. Run:")
+		fmt.Println("  cc-provider deactivate --local")
+		fmt.Println("\n(If the shell function is not loaded, use: eval \"$(command cc-provider deactivate --local --eval)\")")
+		return
+	}
+
+	fmt.Println(script.message)
+	fmt.Println("\nNo environment will be active in new shell sessions.")
+	fmt.Println("To deactivate immediately in current shell, run:")
+	fmt.Println("  cc-provider deactivate")
+	fmt.Println("\n(If the shell function is not loaded, use: eval \"$(command cc-provider deactivate --eval)\")")
+}
+
+// deactivateActiveEnv clears the active environment scripts, so that every
+// managed key is unset in new shells, and records that no environment is
+// active. The caller must hold the config lock.
+// 清空当前环境脚本并记录没有激活的环境
+func deactivateActiveEnv(previous string) (*activationScript, error) {
+	script := newDeactivationScript(previous)
+	if err := writeActiveEnvScript(script); err != nil {
+		return nil, fmt.Errorf("writing active environment script: %w", err)
+	}
+	if err := updateRegistry(func(r *Registry) { r.ClearActive() }); err != nil {
+		return nil, fmt.Errorf("updating environment registry: %w", err)
+	}
+	return script, nil
+}
+
+func init() {
+	rootCmd.AddCommand(deactivateCmd)
+	deactivateCmd.Flags().BoolVarP(&deactivateEval, "eval", "e", false, "Output shell commands for eval (use with: eval \"$(cc-provider deactivate --eval)\")")
+	deactivateCmd.Flags().BoolVar(&deactivateLocal, "local", false, "Only deactivate in the current shell, keeping the default environment")
+	deactivateCmd.Flags().BoolVar(&deactivateGlobal, "global", false, "Only clear the default environment, keeping the current shell")
+	deactivateCmd.MarkFlagsMutuallyExclusive("local", "global")
+	deactivateCmd.Flags().StringVar(&deactivateShell, "shell", "bash", "Shell the --eval output is meant for ("+strings.Join(shellDialectNames(), ", ")+")")
+	deactivateCmd.RegisterFlagCompletionFunc("shell", cobra.FixedCompletions(shellDialectNames(), cobra.ShellCompDirectiveNoFileComp))
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var defaultCmd = &cobra.Command{
+	Use:   "default",
+	Short: "Show or change the default environment.",
+	Long: `Show or change the default environment, which new shells start with.
+Changing the default leaves the current shell alone.`,
+	Annotations: map[string]string{readOnlyAnnotation: "true"},
+	Run:         runDefaultShowCmd,
+}
+
+var defaultShowCmd = &cobra.Command{
+	Use:         "show",
+	Short:       "Show the default environment.",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{readOnlyAnnotation: "true"},
+	Run:         runDefaultShowCmd,
+}
+
+var defaultSetCmd = &cobra.Command{
+	Use:               "set <env-name>",
+	Short:             "Set the default environment.",
+	Long:              `Set the default environment, like 'cc-provider activate --global'.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	Run:               runDefaultSetCmd,
+}
+
+var defaultUnsetCmd = &cobra.Command{
+	Use:   "unset",
+	Short: "Clear the default environment.",
+	Long:  `Clear the default environment, like 'cc-provider deactivate --global'.`,
+	Args:  cobra.NoArgs,
+	Run:   runDefaultUnsetCmd,
+}
+
+func runDefaultShowCmd(cmd *cobra.Command, args []string) {
+	reg := mustLoadRegistry()
+	if reg.Active == "" {
+		fmt.Println("No default environment.")
+		return
+	}
+	fmt.Println(reg.Active)
+}
+
+func runDefaultSetCmd(cmd *cobra.Command, args []string) {
+	envName := args[0]
+	mustValidateEnvName(envName)
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	if !mustLoadRegistry().Has(envName) {
+		fmt.Fprintf(os.Stderr, "Error: Environment '%s' not found.\n", envName)
+		os.Exit(1)
+	}
+	if err := setDefaultEnv(envName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Default environment set to '%s'. It will be active in new shell sessions.\n", envName)
+}
+
+func runDefaultUnsetCmd(cmd *cobra.Command, args []string) {
+	unlock := mustLockConfig()
+	defer unlock()
+
+	if _, err := deactivateActiveEnv(mustLoadRegistry().Active); err != nil {
+		fmt.Fprintf(os.Stderr, "Error deactivating environment: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Default environment cleared. No environment will be active in new shell sessions.")
+}
+
+func init() {
+	rootCmd.AddCommand(defaultCmd)
+	defaultCmd.AddCommand(defaultShowCmd, defaultSetCmd, defaultUnsetCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+)
+
+// Commands run through the shell function can change the shell they were run
+// from. The function creates an empty file, passes its path in directivesEnv
+// and its dialect in directivesShellEnv, and once the command exits it
+// evaluates whatever the command wrote there. This way every argument is
+// passed to the command unchanged, and any command can update the shell.
+// 通过 shell 函数运行的命令可以把需要执行的 shell 更新写入指令文件,由函数在命令结束后执行。
+const (
+	directivesEnv      = "CC_PROVIDER_DIRECTIVES"
+	directivesShellEnv = "CC_PROVIDER_DIRECTIVES_SHELL"
+)
+
+// applyToShell asks the shell function to apply script to the current shell
+// once the command exits. It reports false when the command was not run by
+// the shell function, so the caller can tell the user what to run instead.
+func applyToShell(script *activationScript) (bool, error) {
+	path := os.Getenv(directivesEnv)
+	dialect, ok := shellDialects[os.Getenv(directivesShellEnv)]
+	if path == "" || !ok {
+		return false, nil
+	}
+
+	// Only ever append to the file the shell function created
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return false, fmt.Errorf("opening directives file: %w", err)
+	}
+	if _, err := f.WriteString(dialect.eval(script)); err != nil {
+		f.Close()
+		return false, fmt.Errorf("writing directives file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return false, fmt.Errorf("writing directives file: %w", err)
+	}
+	return true, nil
+}
+
+// refreshEnvironment brings the default environment scripts and the current
+// shell up to date after envName changed, including when they use an
+// environment extending envName. The caller must hold the config lock.
+// 环境修改后,更新默认环境脚本和当前 shell
+func refreshEnvironment(envName string) error {
+	if def := mustLoadRegistry().Active; def != "" && environmentUses(def, envName) {
+		script, err := loadActiveEnvScript(def)
+		if err != nil {
+			return err
+		}
+		if err := writeActiveEnvScript(script); err != nil {
+			return fmt.Errorf("writing active environment script: %w", err)
+		}
+	}
+
+	active := os.Getenv("CC_PROVIDER_ACTIVE_ENV")
+	if active == "" || !environmentUses(active, envName) {
+		return nil
+	}
+	// The change itself is saved, so a secret that cannot be resolved now,
+	// such as one in a vault that would have to be unlocked under the lock,
+	// only leaves the shell for the user to reload
+	script, err := shellActivationScript(active)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: The current shell uses this environment, but it could not be reloaded: %v\n", err)
+		fmt.Printf("To apply the changes, run: cc-provider activate --local %s\n", active)
+		return nil
+	}
+	script.message = fmt.Sprintf("Environment '%s' reloaded.", active)
+	applied, err := applyToShell(script)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		fmt.Println("Note: The current shell uses this environment.")
+		fmt.Printf("To apply the changes, run: cc-provider activate --local %s\n", active)
+	}
+	return nil
+}
+
+// shellActivationScript rebuilds the activation the current shell has for
+// active, including the overrides of the project the shell is in.
+func shellActivationScript(active string) (*activationScript, error) {
+	if path := os.Getenv(projectPathEnv); path != "" {
+		if proj, err := loadProjectFile(path); err == nil && proj.envName == active {
+			return projectActivationScript(proj, os.Getenv(projectPreviousEnv))
+		}
+	}
+	return loadActivationScript(active)
+}
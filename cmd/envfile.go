@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// envLine is a single line of an environment file. Lines that do not hold a
+// variable (comments, blank lines, anything unparseable) keep only their raw
+// text so they can be written back untouched.
+type envLine struct {
+	raw   string
+	key   string
+	value string
+	isVar bool
+}
+
+// EnvVar is a decoded KEY=VALUE pair from an environment file.
+type EnvVar struct {
+	Key   string
+	Value string
+}
+
+// EnvFile is a lossless document model of an environment file.
+// It keeps comments, blank lines, key order and unknown keys, so a file can be
+// parsed, edited and written back without losing anything the user added by hand.
+// EnvFile 是环境文件的无损文档模型,保留注释、空行、键顺序和未知键。
+type EnvFile struct {
+	lines []*envLine
+}
+
+// NewEnvFile returns an empty environment file document.
+func NewEnvFile() *EnvFile {
+	return &EnvFile{}
+}
+
+// ParseEnvFile parses the content of an environment file.
+// 解析环境文件内容
+func ParseEnvFile(content string) *EnvFile {
+	doc := NewEnvFile()
+	if content == "" {
+		return doc
+	}
+
+	content = strings.TrimSuffix(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for _, raw := range strings.Split(content, "\n") {
+		line := &envLine{raw: raw}
+		if key, value, ok := parseEnvLine(raw); ok {
+			line.key = key
+			line.value = value
+			line.isVar = true
+		}
+		doc.lines = append(doc.lines, line)
+	}
+	return doc
+}
+
+// readEnvDocument reads and parses an environment file from disk.
+func readEnvDocument(filePath string) (*EnvFile, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEnvFile(string(content)), nil
+}
+
+// writeEnvDocument serializes an environment file document to disk.
+func writeEnvDocument(filePath string, doc *EnvFile) error {
+	return writeFileAtomic(filePath, []byte(doc.String()), 0600)
+}
+
+// parseEnvLine decodes a `KEY=VALUE` line. An optional leading `export ` is
+// accepted so files written for shells can be read as well.
+func parseEnvLine(raw string) (string, string, bool) {
+	line := strings.TrimSpace(raw)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	line = strings.TrimPrefix(line, "export ")
+
+	parts := strings.SplitN(line, "=", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	key := strings.TrimSpace(parts[0])
+	if key == "" || strings.ContainsAny(key, " \t") {
+		return "", "", false
+	}
+	return key, decodeEnvValue(strings.TrimSpace(parts[1])), true
+}
+
+// decodeEnvValue removes quoting from a raw value the way a shell does.
+// In double-quoted values a backslash escapes only ", \\, $ and the backtick;
+// single-quoted values are literal.
+func decodeEnvValue(s string) string {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return s[1 : len(s)-1]
+	}
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	s = s[1 : len(s)-1]
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case '"', '\\', '$', '`':
+			sb.WriteByte(s[i])
+		default:
+			sb.WriteByte('\\')
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// encodeEnvValue quotes a value the way cc-provider writes it: `"..."` with
+// backslash escapes for characters that would otherwise break the quoting.
+// $ and the backtick are escaped too, so a shell sourcing the file never
+// expands or runs anything in a value. Tabs are kept as they are; values
+// never hold newlines, see validateEnvValue.
+func encodeEnvValue(value string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"', '\\', '$', '`':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// validateEnvValue rejects values an environment file cannot hold. A file
+// has one variable per line and is sourced by shells, which have no escape
+// for a newline inside double quotes, so control characters other than the
+// tab are refused.
+// 拒绝包含控制字符(制表符除外)的值
+func validateEnvValue(value string) error {
+	for _, r := range value {
+		if r != '\t' && (r < 0x20 || r == 0x7f) {
+			return fmt.Errorf("value contains the control character %q", r)
+		}
+	}
+	return nil
+}
+
+// formatEnvLine renders a single KEY="VALUE" line.
+func formatEnvLine(key, value string) string {
+	return fmt.Sprintf("%s=%s", key, encodeEnvValue(value))
+}
+
+// find returns the last line holding key, matching shell semantics where the
+// last assignment wins.
+func (f *EnvFile) find(key string) *envLine {
+	for i := len(f.lines) - 1; i >= 0; i-- {
+		if f.lines[i].isVar && f.lines[i].key == key {
+			return f.lines[i]
+		}
+	}
+	return nil
+}
+
+// Get returns the value of key and whether it is set.
+func (f *EnvFile) Get(key string) (string, bool) {
+	if line := f.find(key); line != nil {
+		return line.value, true
+	}
+	return "", false
+}
+
+// Has reports whether key is set.
+func (f *EnvFile) Has(key string) bool {
+	return f.find(key) != nil
+}
+
+// Set updates key in place, or inserts it at its canonical position if it
+// is not present yet.
+// 原地更新键;若键不存在,则按规范顺序插入。
+func (f *EnvFile) Set(key, value string) {
+	if line := f.find(key); line != nil {
+		if line.value != value {
+			line.value = value
+			line.raw = formatEnvLine(key, value)
+		}
+		return
+	}
+
+	newLine := &envLine{raw: formatEnvLine(key, value), key: key, value: value, isVar: true}
+
+	// Insert after the last variable that sorts before the new key, so that
+	// new files come out in canonical order and existing files stay stable.
+	insertAt := len(f.lines)
+	rank := canonicalKeyRank(key)
+	for i := len(f.lines) - 1; i >= 0; i-- {
+		line := f.lines[i]
+		if !line.isVar {
+			continue
+		}
+		if compareEnvKeys(line.key, key, canonicalKeyRank(line.key), rank) <= 0 {
+			insertAt = i + 1
+			break
+		}
+		insertAt = i
+	}
+
+	f.lines = append(f.lines, nil)
+	copy(f.lines[insertAt+1:], f.lines[insertAt:])
+	f.lines[insertAt] = newLine
+}
+
+// Unset removes every assignment of key. It reports whether anything was removed.
+func (f *EnvFile) Unset(key string) bool {
+	removed := false
+	kept := f.lines[:0]
+	for _, line := range f.lines {
+		if line.isVar && line.key == key {
+			removed = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	f.lines = kept
+	return removed
+}
+
+// Rename changes the name of key in place, keeping its value and position.
+// If newKey is already set, the old key is simply removed.
+func (f *EnvFile) Rename(oldKey, newKey string) {
+	if f.Has(newKey) {
+		f.Unset(oldKey)
+		return
+	}
+	for _, line := range f.lines {
+		if line.isVar && line.key == oldKey {
+			line.key = newKey
+			line.raw = formatEnvLine(newKey, line.value)
+		}
+	}
+}
+
+// Keys returns the variable names in file order, without duplicates.
+func (f *EnvFile) Keys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, line := range f.lines {
+		if line.isVar && !seen[line.key] {
+			seen[line.key] = true
+			keys = append(keys, line.key)
+		}
+	}
+	return keys
+}
+
+// Vars returns the decoded variables in file order. When a key is assigned
+// more than once, the last value is used at the position of the first.
+func (f *EnvFile) Vars() []EnvVar {
+	keys := f.Keys()
+	vars := make([]EnvVar, 0, len(keys))
+	for _, key := range keys {
+		value, _ := f.Get(key)
+		vars = append(vars, EnvVar{Key: key, Value: value})
+	}
+	return vars
+}
+
+// Map returns the decoded variables as a map.
+func (f *EnvFile) Map() map[string]string {
+	m := make(map[string]string)
+	for _, v := range f.Vars() {
+		m[v.Key] = v.Value
+	}
+	return m
+}
+
+// String serializes the document. Untouched lines are written back byte for byte.
+func (f *EnvFile) String() string {
+	if len(f.lines) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, line := range f.lines {
+		sb.WriteString(line.raw)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// canonicalKeyRank returns the position of key in the canonical order used for
+// new files. The base environment comes first, then the keys cc-provider
+// manages, in the order of envVarKeys; unknown keys share the last rank and
+// are sorted alphabetically.
+func canonicalKeyRank(key string) int {
+	if key == extendsKey {
+		return -1
+	}
+	for i, k := range envVarKeys {
+		if k == key {
+			return i
+		}
+	}
+	return len(envVarKeys)
+}
+
+// compareEnvKeys orders two keys canonically.
+func compareEnvKeys(a, b string, rankA, rankB int) int {
+	if rankA != rankB {
+		return rankA - rankB
+	}
+	return strings.Compare(a, b)
+}
+
+// sortedEnvKeys returns the keys of vars in canonical order.
+func sortedEnvKeys(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return compareEnvKeys(a, b, canonicalKeyRank(a), canonicalKeyRank(b))
+	})
+	return keys
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"os/exec"
+	"testing"
+)
+
+var envValueTests = []string{
+	"plain",
+	"",
+	`say "hi"`,
+	`back\slash`,
+	"https://x/$(id)",
+	"${HOME} and $PATH",
+	"`whoami`",
+	"tab\there",
+	"it's",
+	`literal \n`,
+}
+
+func TestEncodeEnvValueRoundTrip(t *testing.T) {
+	for _, value := range envValueTests {
+		if got := decodeEnvValue(encodeEnvValue(value)); got != value {
+			t.Errorf("decodeEnvValue(encodeEnvValue(%q)) = %q (encoded as %s)", value, got, encodeEnvValue(value))
+		}
+	}
+}
+
+// TestEncodeEnvValueSourceable checks that sourcing a written file in a shell
+// yields the values as they are, without expanding or running anything.
+func TestEncodeEnvValueSourceable(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not installed")
+	}
+	for _, value := range envValueTests {
+		out, err := exec.Command(sh, "-c", "VALUE="+encodeEnvValue(value)+`; printf '%s' "$VALUE"`).Output()
+		if err != nil {
+			t.Fatalf("sourcing %s: %v", encodeEnvValue(value), err)
+		}
+		if string(out) != value {
+			t.Errorf("sourcing %s gave %q, want %q", encodeEnvValue(value), out, value)
+		}
+	}
+}
+
+func TestValidateEnvValue(t *testing.T) {
+	for _, value := range envValueTests {
+		if err := validateEnvValue(value); err != nil {
+			t.Errorf("validateEnvValue(%q) = %v, want nil", value, err)
+		}
+	}
+	for _, value := range []string{"line one\nline two", "cr\r", "nul\x00"} {
+		if err := validateEnvValue(value); err == nil {
+			t.Errorf("validateEnvValue(%q) = nil, want an error", value)
+		}
+	}
+}
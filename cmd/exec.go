@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <env-name> [--] <command> [args...]",
+	Short: "Runs a command under a provider environment without activating it.",
+	Long: `Runs a command with the variables of a provider environment. The variables managed
+by cc-provider are removed from the inherited environment first, so nothing from the
+active environment leaks into the command. The active environment and the current
+shell are left untouched, which makes exec suitable for one-off runs, scripts and CI.
+
+The command replaces cc-provider, so its input, output, signals and exit code are
+those of the command itself.
+
+Example:
+  cc-provider exec deepseek -- claude -p "Summarize the changes"`,
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeExecArgs,
+	Annotations:       map[string]string{readOnlyAnnotation: "true"},
+	Run:               runExecCmd,
+}
+
+func runExecCmd(cmd *cobra.Command, args []string) {
+	envName, command := args[0], args[1:]
+	if command[0] == "--" {
+		command = command[1:]
+	}
+	if len(command) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: No command given.\n")
+		os.Exit(1)
+	}
+
+	script := mustLoadEnvironmentScript(envName)
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(127)
+	}
+
+	if err := execProcess(path, command, script.environ(os.Environ())); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running '%s': %v\n", command[0], err)
+		os.Exit(126)
+	}
+}
+
+// mustLoadEnvironmentScript validates envName and builds its activation, or
+// exits with an error.
+func mustLoadEnvironmentScript(envName string) *activationScript {
+	mustValidateEnvName(envName)
+	if !mustLoadRegistry().Has(envName) {
+		fmt.Fprintf(os.Stderr, "Error: Environment '%s' not found.\n", envName)
+		os.Exit(1)
+	}
+
+	script, err := loadActivationScript(envName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading environment: %v\n", err)
+		os.Exit(1)
+	}
+	return script
+}
+
+// completeExecArgs completes the environment name, then the command.
+func completeExecArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return getEnvironmentNames(), cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveDefault
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+	// Flags after the environment name belong to the command
+	execCmd.Flags().SetInterspersed(false)
+}
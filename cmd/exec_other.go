@@ -0,0 +1,36 @@
+//go:build !unix
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+// execProcess runs the program at path as a child, since the process cannot
+// be replaced on this platform. Interrupts are left to the child, and
+// cc-provider exits with its exit code. It only returns if the program could
+// not be started.
+func execProcess(path string, argv, env []string) error {
+	c := exec.Command(path, argv[1:]...)
+	c.Env = env
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	signal.Ignore(os.Interrupt)
+	if err := c.Start(); err != nil {
+		return err
+	}
+
+	err := c.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+	return nil
+}
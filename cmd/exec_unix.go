@@ -0,0 +1,11 @@
+//go:build unix
+
+package cmd
+
+import "syscall"
+
+// execProcess replaces cc-provider with the program at path. It only returns
+// if the program could not be started.
+func execProcess(path string, argv, env []string) error {
+	return syscall.Exec(path, argv, env)
+}
@@ -3,8 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -17,7 +15,8 @@ var exportCmd = &cobra.Command{
 	Short: "Exports an environment's configuration in .env format.",
 	Long: `Exports the configuration of a specified environment to standard output in KEY=VALUE format.
 If no environment is specified with the --name flag, it defaults to the currently active environment.`,
-	Run: runExportCmd,
+	Annotations: map[string]string{readOnlyAnnotation: "true"},
+	Run:         runExportCmd,
 }
 
 func runExportCmd(cmd *cobra.Command, args []string) {
@@ -33,27 +32,40 @@ func runExportCmd(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	envFilePath := filepath.Join(cfgDir, envName)
+	mustValidateEnvName(envName)
+	envFilePath := environmentPath(envName)
 
 	// Validate environment exists
-	if _, err := os.Stat(envFilePath); os.IsNotExist(err) {
+	if !mustLoadRegistry().Has(envName) {
 		fmt.Fprintf(os.Stderr, "Error: Environment '%s' not found.\n", envName)
 		os.Exit(1)
 	}
 
-	// Read and print the environment file content
-	content, err := os.ReadFile(envFilePath)
+	// Read the environment file, merge the environments it extends and resolve
+	// secret references. Inherited keys are inserted at their canonical position.
+	doc, err := readEnvDocument(envFilePath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading environment file '%s': %v\n", envFilePath, err)
 		os.Exit(1)
 	}
-
-	// The content is already in KEY="VALUE" format, so just print it.
-	fmt.Print(string(content))
-	// Ensure there's a newline at the end of the output.
-	if !strings.HasSuffix(string(content), "\n") {
-		fmt.Println()
+	resolved, err := resolveEnvironment(envName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving environment '%s': %v\n", envName, err)
+		os.Exit(1)
+	}
+	migrateEnvDocument(doc)
+	doc.Unset(extendsKey)
+	vars, err := resolveVars(resolved.vars)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving environment '%s': %v\n", envName, err)
+		os.Exit(1)
 	}
+	for _, v := range vars {
+		doc.Set(v.Key, v.Value)
+	}
+
+	// The document is already in KEY="VALUE" format, so just print it.
+	fmt.Print(doc.String())
 }
 
 // completeEnvironmentNamesForExport provides completion for environment names
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// extendsKey names the base environment an environment file extends. The
+// environment only holds the keys it overrides; everything else comes from
+// its base, which may extend another environment in turn.
+const extendsKey = "CC_PROVIDER_EXTENDS"
+
+// resolvedEnv is an environment merged with the environments it extends.
+// 合并了继承链后的环境
+type resolvedEnv struct {
+	// chain is the environment itself, followed by each base in turn.
+	chain []string
+	// vars are the effective variables, secret references unresolved.
+	vars []EnvVar
+	// layers maps each key to the environment in the chain that set it.
+	layers map[string]string
+}
+
+// resolveEnvironment reads envName and the environments it extends and merges
+// them, each layer overriding the keys of its base.
+// 读取环境及其继承链并合并,检测循环继承
+func resolveEnvironment(envName string) (*resolvedEnv, error) {
+	r := &resolvedEnv{layers: make(map[string]string)}
+	var docs []*EnvFile
+	for name := envName; name != ""; {
+		if slices.Contains(r.chain, name) {
+			return nil, fmt.Errorf("environment inheritance cycle: %s", strings.Join(append(r.chain, name), " -> "))
+		}
+		doc, err := readBaseDocument(r.chain, name)
+		if err != nil {
+			return nil, err
+		}
+		r.chain = append(r.chain, name)
+		docs = append(docs, doc)
+		name = baseEnvName(doc)
+	}
+
+	// Merge from the outermost base up, so the canonical key order is kept
+	merged := NewEnvFile()
+	for i := len(docs) - 1; i >= 0; i-- {
+		for _, v := range docs[i].Vars() {
+			if v.Key == extendsKey {
+				continue
+			}
+			merged.Set(v.Key, v.Value)
+			r.layers[v.Key] = r.chain[i]
+		}
+	}
+	r.vars = merged.Vars()
+	return r, nil
+}
+
+// readBaseDocument reads the environment name, reached through chain.
+func readBaseDocument(chain []string, name string) (*EnvFile, error) {
+	if len(chain) > 0 {
+		child := chain[len(chain)-1]
+		if err := validateEnvName(name); err != nil {
+			return nil, fmt.Errorf("environment '%s' extends an invalid name: %w", child, err)
+		}
+		if _, err := os.Stat(environmentPath(name)); os.IsNotExist(err) {
+			return nil, fmt.Errorf("environment '%s' extends '%s', which does not exist", child, name)
+		}
+	}
+
+	doc, err := readEnvDocument(environmentPath(name))
+	if err != nil {
+		return nil, fmt.Errorf("reading environment '%s': %w", name, err)
+	}
+	migrateEnvDocument(doc)
+	return doc, nil
+}
+
+// baseEnvName returns the environment doc extends, if any.
+func baseEnvName(doc *EnvFile) string {
+	base, _ := doc.Get(extendsKey)
+	return strings.TrimSpace(base)
+}
+
+// Map returns the effective variables as a map.
+func (r *resolvedEnv) Map() map[string]string {
+	m := make(map[string]string)
+	for _, v := range r.vars {
+		m[v.Key] = v.Value
+	}
+	return m
+}
+
+// inheritedValues returns the effective variables of base, or none if base
+// is empty. They are what an environment extending base starts with.
+func inheritedValues(base string) (map[string]string, error) {
+	if base == "" {
+		return map[string]string{}, nil
+	}
+	resolved, err := resolveEnvironment(base)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.Map(), nil
+}
+
+// environmentUses reports whether envName is base or extends it. An
+// environment that cannot be resolved is assumed to use it.
+func environmentUses(envName, base string) bool {
+	resolved, err := resolveEnvironment(envName)
+	return err != nil || slices.Contains(resolved.chain, base)
+}
+
+// dependentEnvironments returns the environments that extend envName directly.
+func dependentEnvironments(envName string) []string {
+	var dependents []string
+	for _, name := range getEnvironmentNames() {
+		if name == envName {
+			continue
+		}
+		doc, err := readEnvDocument(environmentPath(name))
+		if err == nil && baseEnvName(doc) == envName {
+			dependents = append(dependents, name)
+		}
+	}
+	return dependents
+}
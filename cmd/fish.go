@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fishQuote quotes s for fish. Inside single quotes fish only treats \\ and \'
+// specially, so those are escaped and nothing else is expanded.
+// 为 fish shell 安全地引用字符串
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "'", `\'`)
+	return "'" + s + "'"
+}
+
+// fishDialect activates with set -gx and set -e.
+var fishDialect = commandDialect{
+	ext:   ".fish",
+	unset: func(key string) string { return "set -e " + key },
+	set:   func(key, value string) string { return fmt.Sprintf("set -gx %s %s", key, fishQuote(value)) },
+	setResolved: func(key string, args []string) string {
+		return fmt.Sprintf("set -gx %s (command cc-provider %s | string collect)", key, quoteEach(args, fishQuote))
+	},
+	echoErr: func(msg string) string { return fmt.Sprintf("echo %s >&2", fishQuote(msg)) },
+	unsetRecorded: func(fallback []string) string {
+		// The loop variable is local, so no variable of the user is touched
+		return fmt.Sprintf(`if set -q %[1]s
+    set -l __cc_provider_key
+    for __cc_provider_key in (string split -n ' ' -- $%[1]s)
+        set -e $__cc_provider_key
+    end
+else
+    set -e %[2]s
+end`, managedKeysEnv, strings.Join(fallback, " "))
+	},
+}
+
+// fishConfDir returns the conf.d directory fish sources at startup.
+func fishConfDir(home string) string {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(base) {
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "fish", "conf.d")
+}
+
+// fishShellFunction returns the fish wrapper that enables immediate activation.
+func fishShellFunction() string {
+	return fmt.Sprintf(`# cc-provider shell integration for fish
+# This wraps the cc-provider command to enable immediate activation
+
+function cc-provider --description 'Manage Claude Code provider environments'
+    # Use the config directory this integration was generated for unless overridden
+    set -l home %s
+    set -q CC_PROVIDER_HOME; and set home $CC_PROVIDER_HOME
+    set -lx CC_PROVIDER_HOME $home
+
+    # Commands write the updates for the current shell to a directives file,
+    # which is applied once they exit; arguments are passed on unchanged
+    set -l directives (mktemp -t cc-provider.XXXXXX)
+    or begin
+        command cc-provider $argv
+        return
+    end
+    CC_PROVIDER_DIRECTIVES=$directives CC_PROVIDER_DIRECTIVES_SHELL=fish command cc-provider $argv
+    set -l exit_status $status
+    if test -s $directives
+        source $directives
+    end
+    rm -f $directives
+    return $exit_status
+end
+`, fishQuote(cfgDir))
+}
+
+// fishProjectHook returns the fish code that runs the hook whenever the
+// working directory changes.
+func fishProjectHook() string {
+	return fmt.Sprintf(`
+# Switch environments automatically based on .cc-provider project files
+function __cc_provider_hook --on-variable PWD
+    set -l home %s
+    set -q CC_PROVIDER_HOME; and set home $CC_PROVIDER_HOME
+    CC_PROVIDER_HOME=$home command cc-provider hook --shell fish | source
+end
+
+status is-interactive; and __cc_provider_hook
+`, fishQuote(cfgDir))
+}
+
+// ensureFishConfig installs the fish integration: the wrapper function, the
+// completion and a conf.d snippet sourcing them together with the active
+// environment. The snippet belongs to cc-provider and is rewritten as needed.
+// 安装 fish 集成:包装函数、补全以及 conf.d 片段
+func ensureFishConfig(forceUpdate bool) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding home directory: %w", err)
+	}
+	confPath := filepath.Join(fishConfDir(home), "cc-provider.fish")
+
+	// Create an empty active_env.fish if it doesn't exist, to prevent source errors on shell startup.
+	if _, err := os.Stat(activeEnvScriptPath(fishDialect)); os.IsNotExist(err) {
+		if err := writeGeneratedScript(activeEnvScriptPath(fishDialect), []byte("# cc-provider active environment script\n"), 0600); err != nil {
+			return "", fmt.Errorf("creating empty active_env.fish: %w", err)
+		}
+	}
+
+	shellFunctionPath := filepath.Join(shellDir, "shell_function.fish")
+	shellFunction := fishShellFunction()
+	if projectHookEnabled() {
+		shellFunction += fishProjectHook()
+	}
+	if err := writeGeneratedScript(shellFunctionPath, []byte(shellFunction), 0644); err != nil {
+		return "", fmt.Errorf("creating shell function file: %w", err)
+	}
+
+	completionFilePath := filepath.Join(shellDir, "completion.fish")
+	if _, err := os.Stat(completionFilePath); os.IsNotExist(err) || forceUpdate {
+		var completion bytes.Buffer
+		if err := rootCmd.GenFishCompletion(&completion, true); err != nil {
+			return "", fmt.Errorf("generating completion: %w", err)
+		}
+		if err := writeGeneratedScript(completionFilePath, completion.Bytes(), 0644); err != nil {
+			return "", fmt.Errorf("creating completion file: %w", err)
+		}
+	}
+
+	confContent := fmt.Sprintf(`# Added by cc-provider for environment activation and auto-completion
+set -q %s; or source %s
+source %s
+source %s
+`, subshellEnv, fishQuote(activeEnvScriptPath(fishDialect)), fishQuote(completionFilePath), fishQuote(shellFunctionPath))
+
+	_, statErr := os.Stat(confPath)
+	firstTime := os.IsNotExist(statErr)
+	changed, err := writeShellConfigFile(confPath, []byte(confContent))
+	if err != nil || shellConfigDryRun {
+		return confPath, err
+	}
+
+	if firstTime {
+		fmt.Printf("First-time setup complete. Added configuration to '%s' for automatic environment loading and tab completion.\n", confPath)
+		fmt.Println("Please restart your shell or source your config file to apply changes.")
+	} else if changed {
+		fmt.Printf("Updated shell configuration in '%s'.\n", confPath)
+	}
+	return confPath, nil
+}
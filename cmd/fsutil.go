@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes data to path through a temporary file in the same
+// directory that is renamed over the target, so readers never see a partially
+// written file and a crash leaves either the old or the new content.
+// If path is a symlink, the file it points to is replaced and the link is kept.
+// 通过临时文件加重命名的方式原子写入文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // cleaned up if anything below fails
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("replacing %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeFileIfChanged writes data to path atomically unless the file already
+// holds exactly data. It reports whether the file was written.
+func writeFileIfChanged(path string, data []byte, perm os.FileMode) (bool, error) {
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return false, nil
+	}
+	return true, writeFileAtomic(path, data, perm)
+}
+
+// appendFileAtomic appends text to path, creating it if needed, by rewriting
+// the whole file atomically.
+func appendFileAtomic(path, text string) error {
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return writeFileAtomic(path, append(content, text...), fileModeOr(path, 0644))
+}
+
+// fileModeOr returns the permission bits of path, or def if it does not exist.
+func fileModeOr(path string, def os.FileMode) os.FileMode {
+	if info, err := os.Stat(path); err == nil {
+		return info.Mode().Perm()
+	}
+	return def
+}
+
+// lockPath returns the path of the advisory lock file of the config directory.
+func lockPath() string {
+	return filepath.Join(cfgDir, ".lock")
+}
+
+// configLocked is set while this process holds the config directory lock.
+var configLocked bool
+
+// mustLockConfig takes the config directory lock or exits with an error.
+// The returned function releases it.
+func mustLockConfig() func() {
+	unlock, err := lockConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locking config directory '%s': %v\n", cfgDir, err)
+		os.Exit(1)
+	}
+	configLocked = true
+	return func() {
+		configLocked = false
+		unlock()
+	}
+}
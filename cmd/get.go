@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var getReveal bool // 显示密钥原文 / Show secrets in full
+
+// getCmd prints the value of one variable of an environment, for scripts:
+//
+//	model=$(cc-provider get deepseek ANTHROPIC_MODEL)
+var getCmd = &cobra.Command{
+	Use:   "get <env-name> KEY",
+	Short: "Prints a variable of a provider environment.",
+	Long: `Prints the value of a variable of a provider environment, including values it extends
+from its base, followed by a newline and nothing else.
+
+Secrets are masked and secret references are printed as they are written, unless --reveal
+is given: then references are resolved and the value is printed in full.`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeGetArgs,
+	Annotations:       map[string]string{readOnlyAnnotation: "true"},
+	Run:               runGetCmd,
+}
+
+func runGetCmd(cmd *cobra.Command, args []string) {
+	envName, key := args[0], args[1]
+	mustValidateEnvName(envName)
+	if !mustLoadRegistry().Has(envName) {
+		fmt.Fprintf(os.Stderr, "Error: Environment '%s' not found.\n", envName)
+		os.Exit(1)
+	}
+
+	resolved, err := resolveEnvironment(envName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading environment '%s': %v\n", envName, err)
+		os.Exit(1)
+	}
+	value, ok := resolved.Map()[key]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: %s is not set in environment '%s'.\n", key, envName)
+		os.Exit(1)
+	}
+
+	switch {
+	case getReveal:
+		vars, err := resolveVars([]EnvVar{{Key: key, Value: value}})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error resolving %s: %v\n", key, err)
+			os.Exit(1)
+		}
+		value = vars[0].Value
+	case isSecretRef(value):
+		// Show where the secret comes from, never the secret itself
+	case isSecretKey(key):
+		value = maskSecret(value)
+	}
+	fmt.Println(value)
+}
+
+// completeGetArgs completes the environment name, then its keys.
+func completeGetArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return getEnvironmentNames(), cobra.ShellCompDirectiveNoFileComp
+	case 1:
+		return environmentKeys(args[0], true), cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(getCmd)
+	getCmd.Flags().BoolVar(&getReveal, "reveal", false, "Print secrets in full, resolving secret references")
+}
@@ -0,0 +1,363 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyKind selects whether a history belongs to an environment or a template.
+type historyKind string
+
+const (
+	envHistory      historyKind = "envs"
+	templateHistory historyKind = "templates"
+)
+
+// Revision is a snapshot of an environment or a custom template, recorded
+// after every change. Snapshots are kept under state/history/<kind>/<name>/
+// with one file per revision.
+// Revision 是环境或模板在每次修改后的快照
+type Revision struct {
+	Number   int       `json:"revision"`
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Removed  bool      `json:"removed,omitempty"`
+	Env      string    `json:"env,omitempty"`
+	Meta     *EnvMeta  `json:"meta,omitempty"`
+	Template *Template `json:"template,omitempty"`
+}
+
+// Vars returns the variables of the snapshot as a map.
+func (rev *Revision) Vars() map[string]string {
+	switch {
+	case rev.Removed:
+		return map[string]string{}
+	case rev.Template != nil:
+		return rev.Template.EnvVars
+	}
+	return ParseEnvFile(rev.Env).Map()
+}
+
+// sameState reports whether two snapshots hold the same content.
+func (rev *Revision) sameState(other *Revision) bool {
+	return rev.Removed == other.Removed &&
+		rev.Env == other.Env &&
+		reflect.DeepEqual(rev.Template, other.Template)
+}
+
+// historyDir returns the directory holding the revisions of name.
+func historyDir(kind historyKind, name string) string {
+	return filepath.Join(stateDir, "history", string(kind), name)
+}
+
+// revisionPath returns the path of a revision file.
+func revisionPath(kind historyKind, name string, number int) string {
+	return filepath.Join(historyDir(kind, name), fmt.Sprintf("%06d.json", number))
+}
+
+// loadRevisions returns the revisions of name, oldest first.
+func loadRevisions(kind historyKind, name string) ([]*Revision, error) {
+	entries, err := os.ReadDir(historyDir(kind, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+
+	var revisions []*Revision
+	for _, entry := range entries {
+		number, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil || entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(revisionPath(kind, name, number))
+		if err != nil {
+			return nil, fmt.Errorf("reading revision %d: %w", number, err)
+		}
+		var rev Revision
+		if err := json.Unmarshal(data, &rev); err != nil {
+			return nil, fmt.Errorf("parsing revision %d: %w", number, err)
+		}
+		rev.Number = number
+		revisions = append(revisions, &rev)
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Number < revisions[j].Number })
+	return revisions, nil
+}
+
+// findRevision returns the revision with the given number.
+func findRevision(revisions []*Revision, number int) (*Revision, error) {
+	for _, rev := range revisions {
+		if rev.Number == number {
+			return rev, nil
+		}
+	}
+	return nil, fmt.Errorf("revision %d not found", number)
+}
+
+// historyNames returns the sorted names that have a recorded history,
+// including removed ones.
+func historyNames(kind historyKind) []string {
+	entries, err := os.ReadDir(filepath.Join(stateDir, "history", string(kind)))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// currentSnapshot captures the state of name as it is on disk now.
+func currentSnapshot(kind historyKind, name string) (*Revision, error) {
+	rev := &Revision{Time: time.Now().UTC().Truncate(time.Second)}
+
+	if kind == templateHistory {
+		data, err := os.ReadFile(filepath.Join(templateDir, name+".json"))
+		if os.IsNotExist(err) {
+			rev.Removed = true
+			return rev, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var tmpl Template
+		if err := json.Unmarshal(data, &tmpl); err != nil {
+			return nil, fmt.Errorf("parsing template '%s': %w", name, err)
+		}
+		rev.Template = &tmpl
+		return rev, nil
+	}
+
+	data, err := os.ReadFile(environmentPath(name))
+	if os.IsNotExist(err) {
+		rev.Removed = true
+		return rev, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rev.Env = string(data)
+	if reg, err := loadRegistry(); err == nil {
+		if meta := reg.Get(name); meta != nil {
+			snapshot := *meta
+			snapshot.LastActivated = nil
+			rev.Meta = &snapshot
+		}
+	}
+	return rev, nil
+}
+
+// appendRevision numbers rev after the latest revision and writes it.
+func appendRevision(kind historyKind, name string, revisions []*Revision, rev *Revision) error {
+	rev.Number = 1
+	if len(revisions) > 0 {
+		rev.Number = revisions[len(revisions)-1].Number + 1
+	}
+	if err := os.MkdirAll(historyDir(kind, name), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rev, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Snapshots may hold plaintext secrets, like the files they are taken from
+	return writeFileAtomic(revisionPath(kind, name, rev.Number), data, 0600)
+}
+
+// recordRevision snapshots the current state of name after a change.
+func recordRevision(kind historyKind, name, action string) error {
+	revisions, err := loadRevisions(kind, name)
+	if err != nil {
+		return err
+	}
+	rev, err := currentSnapshot(kind, name)
+	if err != nil {
+		return err
+	}
+	rev.Action = action
+	return appendRevision(kind, name, revisions, rev)
+}
+
+// recordBaseline snapshots the current state of name before a change, unless
+// the latest revision already holds it. This keeps the state of environments
+// created before history was recorded, or edited by hand, restorable.
+func recordBaseline(kind historyKind, name string) error {
+	revisions, err := loadRevisions(kind, name)
+	if err != nil {
+		return err
+	}
+	rev, err := currentSnapshot(kind, name)
+	if err != nil {
+		return err
+	}
+	if len(revisions) == 0 {
+		if rev.Removed {
+			return nil
+		}
+		rev.Action = "baseline"
+	} else {
+		if revisions[len(revisions)-1].sameState(rev) {
+			return nil
+		}
+		rev.Action = "external edit"
+	}
+	return appendRevision(kind, name, revisions, rev)
+}
+
+// recordHistory records a revision after a change. History is a safety net,
+// so a failure is reported without failing the change itself.
+// 记录修改历史,失败时仅给出警告
+func recordHistory(kind historyKind, name, action string) {
+	if err := recordRevision(kind, name, action); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: recording history of '%s': %v\n", name, err)
+	}
+}
+
+// recordHistoryBaseline records the state before a change, see recordBaseline.
+func recordHistoryBaseline(kind historyKind, name string) {
+	if err := recordBaseline(kind, name); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: recording history of '%s': %v\n", name, err)
+	}
+}
+
+// redactHistorySecrets replaces the plaintext secrets kept in the revisions of
+// name with references to the vault entry that now holds the secret. It is
+// used once secrets have been migrated to the vault, so no copy is left behind.
+func redactHistorySecrets(kind historyKind, name string) error {
+	owner := name
+	if kind == templateHistory {
+		owner = "templates/" + name
+	}
+	redact := func(key, value string) (string, bool) {
+		if !isSecretKey(key) || value == "" || isSecretRef(value) {
+			return value, false
+		}
+		return vaultRefPrefix + vaultEntryName(owner, key), true
+	}
+	return rewriteHistoryValues(kind, name, redact)
+}
+
+// rewriteHistoryValues rewrites the values kept in the revisions of name.
+// rewrite returns the new value of key and whether it changed.
+func rewriteHistoryValues(kind historyKind, name string, rewrite func(key, value string) (string, bool)) error {
+	revisions, err := loadRevisions(kind, name)
+	if err != nil {
+		return err
+	}
+
+	for _, rev := range revisions {
+		changed := false
+		switch {
+		case rev.Template != nil:
+			for key, value := range rev.Template.EnvVars {
+				if newValue, ok := rewrite(key, value); ok {
+					rev.Template.EnvVars[key] = newValue
+					changed = true
+				}
+			}
+		case rev.Env != "":
+			doc := ParseEnvFile(rev.Env)
+			for _, ev := range doc.Vars() {
+				if newValue, ok := rewrite(ev.Key, ev.Value); ok {
+					doc.Set(ev.Key, newValue)
+					changed = true
+				}
+			}
+			rev.Env = doc.String()
+		}
+		if !changed {
+			continue
+		}
+
+		data, err := json.MarshalIndent(rev, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(revisionPath(kind, name, rev.Number), data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// varChange is a difference in one variable between two snapshots.
+type varChange struct {
+	Key      string
+	Old, New string
+	HadOld   bool
+	HasNew   bool
+}
+
+// diffVars compares two sets of variables, in canonical key order.
+func diffVars(oldVars, newVars map[string]string) []varChange {
+	keys := make(map[string]bool)
+	for key := range oldVars {
+		keys[key] = true
+	}
+	for key := range newVars {
+		keys[key] = true
+	}
+
+	var changes []varChange
+	for key := range keys {
+		oldValue, hadOld := oldVars[key]
+		newValue, hasNew := newVars[key]
+		if hadOld == hasNew && oldValue == newValue {
+			continue
+		}
+		changes = append(changes, varChange{Key: key, Old: oldValue, New: newValue, HadOld: hadOld, HasNew: hasNew})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i].Key, changes[j].Key
+		return compareEnvKeys(a, b, canonicalKeyRank(a), canonicalKeyRank(b)) < 0
+	})
+	return changes
+}
+
+// displayValue returns value as it may be shown to the user, masking secrets.
+func displayValue(key, value string) string {
+	if isSecretKey(key) && !isSecretRef(value) {
+		return maskSecret(value)
+	}
+	return value
+}
+
+// historyValue returns value as diff shows it unless --reveal is given. Every
+// variable cc-provider does not know is masked along with the secrets, since
+// extra variables such as HTTPS_PROXY or ANTHROPIC_CUSTOM_HEADERS can hold
+// credentials too; the credentials in a URL are masked as well.
+// 历史差异中除已知变量外一律掩码,URL 中的用户信息也会掩码
+func historyValue(key, value string) string {
+	switch {
+	case value == "" || isSecretRef(value):
+		return value
+	case isSecretKey(key) || (key != extendsKey && !slices.Contains(envVarKeys, key)):
+		return maskSecret(value)
+	}
+	return maskURLUserinfo(value)
+}
+
+// maskURLUserinfo masks the user name and password of a URL value.
+func maskURLUserinfo(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	u.User = nil
+	return strings.Replace(u.String(), "//", "//****@", 1)
+}
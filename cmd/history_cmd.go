@@ -0,0 +1,356 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var historyOfTemplate bool // 是否操作模板的历史 / Whether to work on the history of a template
+var diffReveal bool        // 显示变量原文 / Show values in full
+
+var historyCmd = &cobra.Command{
+	Use:   "history [env-name]",
+	Short: "Lists the recorded revisions of an environment.",
+	Long: `Lists the revisions recorded for an environment, or with --template for a custom template.
+A revision is recorded every time it is created, modified, removed or rolled back.
+The revision matching the current state is marked with an asterisk (*).`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeHistoryArgs,
+	Annotations:       map[string]string{readOnlyAnnotation: "true"},
+	Run:               runHistoryCmd,
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [env-name] [rev] [other-rev]",
+	Short: "Shows what changed in a revision.",
+	Long: `Shows what changed in a revision compared with the revision before it, or compared
+with another revision if one is given. Secrets, the credentials in URLs and the
+values of variables cc-provider does not know, which may hold credentials too, are
+masked unless --reveal is given.`,
+	Args:              cobra.RangeArgs(2, 3),
+	ValidArgsFunction: completeHistoryArgs,
+	Annotations:       map[string]string{readOnlyAnnotation: "true"},
+	Run:               runDiffCmd,
+}
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [env-name] [rev]",
+	Short: "Restores an earlier revision of an environment.",
+	Long: `Restores an environment, or with --template a custom template, to an earlier revision.
+Without a revision, the change recorded last is undone. Removed environments can be
+restored as well. The rollback itself is recorded as a new revision, so it can be undone too.
+
+Secrets kept in the vault are referenced by revisions, not copied: restoring a
+revision restores the reference, which points at the secret currently in the vault.`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeHistoryArgs,
+	Run:               runRollbackCmd,
+}
+
+func runHistoryCmd(cmd *cobra.Command, args []string) {
+	kind, name := historyTarget(args[0])
+	revisions := mustLoadRevisions(kind, name)
+
+	current, err := currentSnapshot(kind, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading '%s': %v\n", name, err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  REV\tTIME\tACTION\tCHANGES")
+	var previous *Revision
+	for _, rev := range revisions {
+		marker := " "
+		if rev.sameState(current) {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s %d\t%s\t%s\t%s\n", marker, rev.Number, formatListTime(rev.Time), rev.Action, summarizeRevision(previous, rev))
+		previous = rev
+	}
+	w.Flush()
+}
+
+// summarizeRevision describes the changes of rev compared with previous.
+func summarizeRevision(previous, rev *Revision) string {
+	switch {
+	case rev.Removed:
+		return "removed"
+	case previous == nil || previous.Removed:
+		return fmt.Sprintf("%d variable(s)", len(rev.Vars()))
+	}
+
+	var parts []string
+	for _, change := range diffVars(previous.Vars(), rev.Vars()) {
+		switch {
+		case !change.HadOld:
+			parts = append(parts, "+"+change.Key)
+		case !change.HasNew:
+			parts = append(parts, "-"+change.Key)
+		default:
+			parts = append(parts, "~"+change.Key)
+		}
+	}
+	return orDash(strings.Join(parts, " "))
+}
+
+func runDiffCmd(cmd *cobra.Command, args []string) {
+	kind, name := historyTarget(args[0])
+	revisions := mustLoadRevisions(kind, name)
+
+	rev := mustFindRevision(revisions, args[1])
+	var base *Revision
+	if len(args) == 3 {
+		base, rev = rev, mustFindRevision(revisions, args[2])
+	} else {
+		for _, r := range revisions {
+			if r.Number < rev.Number {
+				base = r
+			}
+		}
+	}
+
+	baseVars := map[string]string{}
+	if base != nil {
+		baseVars = base.Vars()
+		fmt.Printf("Revision %d (%s) compared with revision %d (%s):\n", rev.Number, rev.Action, base.Number, base.Action)
+	} else {
+		fmt.Printf("Revision %d (%s), the first recorded revision:\n", rev.Number, rev.Action)
+	}
+
+	changes := diffVars(baseVars, rev.Vars())
+	if len(changes) == 0 {
+		fmt.Println("  No changes.")
+		return
+	}
+	for _, change := range changes {
+		if change.HadOld {
+			fmt.Printf("- %s=%s\n", change.Key, diffValue(change.Key, change.Old))
+		}
+		if change.HasNew {
+			fmt.Printf("+ %s=%s\n", change.Key, diffValue(change.Key, change.New))
+		}
+	}
+}
+
+// diffValue returns value as diff prints it.
+func diffValue(key, value string) string {
+	if diffReveal {
+		return value
+	}
+	return historyValue(key, value)
+}
+
+func runRollbackCmd(cmd *cobra.Command, args []string) {
+	kind, name := historyTarget(args[0])
+	v := mustOpenVaultIf(rollbackNeedsVault(kind, name))
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	// Keep the current state restorable, even if it was edited by hand
+	recordHistoryBaseline(kind, name)
+	revisions := mustLoadRevisions(kind, name)
+
+	var target *Revision
+	if len(args) == 2 {
+		target = mustFindRevision(revisions, args[1])
+	} else if len(revisions) >= 2 {
+		target = revisions[len(revisions)-2]
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: No earlier revision of '%s' to roll back to.\n", name)
+		os.Exit(1)
+	}
+	if target.Removed {
+		fmt.Fprintf(os.Stderr, "Error: Revision %d records the removal of '%s'; choose an earlier revision.\n", target.Number, name)
+		os.Exit(1)
+	}
+
+	current, err := currentSnapshot(kind, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading '%s': %v\n", name, err)
+		os.Exit(1)
+	}
+	if current.sameState(target) {
+		fmt.Printf("'%s' is already at revision %d.\n", name, target.Number)
+		return
+	}
+
+	if kind == templateHistory {
+		restoreTemplateRevision(v, name, target)
+	} else {
+		restoreEnvRevision(v, name, target)
+	}
+	recordHistory(kind, name, fmt.Sprintf("rollback to %d", target.Number))
+
+	fmt.Printf("Successfully rolled back '%s' to revision %d.\n", name, target.Number)
+
+	// Apply the restored values to the default scripts and the current shell
+	if kind == envHistory {
+		if err := refreshEnvironment(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error applying changes: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// rollbackNeedsVault reports whether restoring a revision of name may move
+// secrets into the vault, which is then opened before the lock is taken.
+func rollbackNeedsVault(kind historyKind, name string) bool {
+	revisions, _ := loadRevisions(kind, name)
+	for _, rev := range revisions {
+		doc := NewEnvFile()
+		for key, value := range rev.Vars() {
+			doc.Set(key, value)
+		}
+		if vaultNeeded(doc, "") {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreEnvRevision writes an environment back as recorded in rev and
+// registers it again if it had been removed.
+func restoreEnvRevision(v *Vault, envName string, rev *Revision) {
+	doc := ParseEnvFile(rev.Env)
+
+	// Secrets recorded before the vault was initialized go into it again
+	if _, _, err := storeSecretsInVault(v, envName, doc); err != nil {
+		fmt.Fprintf(os.Stderr, "Error storing secrets in vault: %v\n", err)
+		os.Exit(1)
+	}
+
+	envFilePath := environmentPath(envName)
+	if err := writeEnvDocument(envFilePath, doc); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing environment file '%s': %v\n", envFilePath, err)
+		os.Exit(1)
+	}
+
+	err := updateRegistry(func(r *Registry) {
+		meta := r.Get(envName)
+		if meta == nil {
+			meta = &EnvMeta{}
+			r.Add(envName, meta)
+		}
+		if rev.Meta != nil {
+			meta.Description = rev.Meta.Description
+			meta.Template = rev.Meta.Template
+			meta.Tags = rev.Meta.Tags
+			if !rev.Meta.CreatedAt.IsZero() {
+				meta.CreatedAt = rev.Meta.CreatedAt
+			}
+		}
+		r.Touch(envName)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating environment registry: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// restoreTemplateRevision saves a custom template back as recorded in rev.
+func restoreTemplateRevision(v *Vault, name string, rev *Revision) {
+	tmpl := *rev.Template
+	if _, _, err := storeTemplateSecretsInVault(v, &tmpl); err != nil {
+		fmt.Fprintf(os.Stderr, "Error storing secrets in vault: %v\n", err)
+		os.Exit(1)
+	}
+	if err := saveCustomTemplate(tmpl); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving template: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// historyTarget returns the kind of history selected by --template and the name.
+func historyTarget(name string) (historyKind, string) {
+	if historyOfTemplate {
+		mustValidateTemplateName(name)
+		if _, ok := builtInTemplates[name]; ok {
+			fmt.Fprintf(os.Stderr, "Error: Built-in template '%s' has no history.\n", name)
+			os.Exit(1)
+		}
+		return templateHistory, name
+	}
+	mustValidateEnvName(name)
+	return envHistory, name
+}
+
+// mustLoadRevisions loads the revisions of name or exits with an error if there are none.
+func mustLoadRevisions(kind historyKind, name string) []*Revision {
+	revisions, err := loadRevisions(kind, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading history of '%s': %v\n", name, err)
+		os.Exit(1)
+	}
+	if len(revisions) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: No history recorded for '%s'.\n", name)
+		os.Exit(1)
+	}
+	return revisions
+}
+
+// mustFindRevision parses a revision number and returns that revision, or exits with an error.
+func mustFindRevision(revisions []*Revision, arg string) *Revision {
+	number, err := strconv.Atoi(arg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid revision '%s'.\n", arg)
+		os.Exit(1)
+	}
+	rev, err := findRevision(revisions, number)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return rev
+}
+
+// completeHistoryArgs completes names with a history, then their revision numbers.
+// 补全有历史记录的名称及其修订号
+func completeHistoryArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	kind := envHistory
+	if historyOfTemplate {
+		kind = templateHistory
+	}
+
+	validate := validateEnvName
+	if kind == templateHistory {
+		validate = validateTemplateName
+	}
+
+	if len(args) == 0 {
+		return validNames(historyNames(kind), validate), cobra.ShellCompDirectiveNoFileComp
+	}
+	if validate(args[0]) != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	maxRevisions := map[string]int{"diff": 2, "rollback": 1}[cmd.Name()]
+	if len(args) > maxRevisions {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	revisions, err := loadRevisions(kind, args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var numbers []string
+	for _, rev := range revisions {
+		numbers = append(numbers, fmt.Sprintf("%d\t%s %s", rev.Number, formatListTime(rev.Time), rev.Action))
+	}
+	return numbers, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(rollbackCmd)
+	for _, c := range []*cobra.Command{historyCmd, diffCmd, rollbackCmd} {
+		c.Flags().BoolVar(&historyOfTemplate, "template", false, "Work on the history of a custom template")
+	}
+	diffCmd.Flags().BoolVar(&diffReveal, "reveal", false, "Print values in full")
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var hookShell string // 输出的目标 shell / Shell the output is meant for
+
+// hookCmd is run by the shell integration when the working directory changes.
+// It prints the commands that switch to the environment of the project file
+// in effect, or that restore the previous environment after leaving one, and
+// nothing when there is no change.
+var hookCmd = &cobra.Command{
+	Use:    "hook",
+	Short:  "Prints the shell commands that apply the .cc-provider file of the current directory.",
+	Hidden: true,
+	// Run on every cd, so it must be fast and never wait for the lock
+	Annotations: map[string]string{readOnlyAnnotation: "true"},
+	Args:        cobra.NoArgs,
+	Run:         runHookCmd,
+}
+
+func runHookCmd(cmd *cobra.Command, args []string) {
+	dialect, ok := shellDialects[hookShell]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: Unsupported shell '%s' (supported: %s).\n", hookShell, strings.Join(shellDialectNames(), ", "))
+		os.Exit(1)
+	}
+
+	// A shell started by 'cc-provider shell' keeps its environment
+	if os.Getenv(subshellEnv) != "" {
+		return
+	}
+	// The hook runs on cd, so a secret that would need a passphrase fails instead
+	promptsDisabled = true
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding current directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	current := os.Getenv(projectPathEnv)
+	path, found := findProjectFile(cwd)
+	if path == current {
+		return
+	}
+
+	var proj *projectFile
+	if found {
+		if proj, err = loadProjectFile(path); err != nil {
+			fmt.Fprintf(os.Stderr, "cc-provider: ignoring %s: %v\n", path, err)
+		}
+	}
+
+	// The environment to restore is the one active before the first project entered
+	previous := os.Getenv("CC_PROVIDER_ACTIVE_ENV")
+	if current != "" {
+		previous = os.Getenv(projectPreviousEnv)
+	}
+
+	var script *activationScript
+	switch {
+	case proj != nil:
+		script, err = projectActivationScript(proj, previous)
+	case current != "":
+		script, err = projectRestoreScript(previous)
+	default:
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cc-provider: not switching environments for %s: %v\n", cwd, err)
+		os.Exit(1)
+	}
+	fmt.Print(dialect.eval(script))
+}
+
+// posixProjectHook returns the bash and zsh code that runs the hook whenever
+// the working directory changed before a prompt is shown.
+func posixProjectHook() string {
+	return fmt.Sprintf(`
+# Switch environments automatically based on .cc-provider project files
+_cc_provider_hook() {
+    local exit_status=$?
+    if [ "$PWD" != "${_CC_PROVIDER_HOOK_PWD-}" ]; then
+        _CC_PROVIDER_HOOK_PWD=$PWD
+        eval "$(CC_PROVIDER_HOME=${CC_PROVIDER_HOME:-%s} command cc-provider hook --shell bash)"
+    fi
+    return $exit_status
+}
+
+if [ -n "${ZSH_VERSION-}" ]; then
+    autoload -Uz add-zsh-hook
+    add-zsh-hook precmd _cc_provider_hook
+else
+    case ";${PROMPT_COMMAND-};" in
+        *";_cc_provider_hook;"*) ;;
+        *) PROMPT_COMMAND="_cc_provider_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
+    esac
+fi
+`, shellQuote(cfgDir))
+}
+
+func init() {
+	rootCmd.AddCommand(hookCmd)
+	hookCmd.Flags().StringVar(&hookShell, "shell", "bash", "Shell the output is meant for ("+strings.Join(shellDialectNames(), ", ")+")")
+}
@@ -1,35 +1,117 @@
 package cmd
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
+// skipShellSetupAnnotation marks commands that must not set up the shell
+// integration before they run, because they manage it themselves.
+const skipShellSetupAnnotation = "cc-provider/skip-shell-setup"
+
+// readOnlyAnnotation marks commands that only read the config directory, such
+// as list or the prompt segment. They never take the config lock, which another
+// command may hold for a while, and skip all setup work; commands that write
+// take the lock themselves.
+const readOnlyAnnotation = "cc-provider/read-only"
+
+// readOnlyRun is set while a read-only command runs.
+var readOnlyRun bool
+
+// isReadOnlyCommand reports whether cmd only reads the config directory: it is
+// marked with readOnlyAnnotation, or it is one of the completion commands,
+// which the shell runs on every tab.
+func isReadOnlyCommand(cmd *cobra.Command) bool {
+	switch cmd.Name() {
+	case cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return true
+	}
+	return cmd == completionCmd || cmd.Annotations[readOnlyAnnotation] != ""
+}
+
+// isUninstallCommand reports whether cmd removes the shell integration.
+func isUninstallCommand(cmd *cobra.Command) bool {
+	return cmd == uninstallCmd || (cmd == setupCmd && setupUninstall)
+}
+
 // Init performs the initial setup for cc-provider.
 // It ensures the configuration directory and necessary files exist,
 // and sets up shell integration if needed.
-func Init() {
-	// This function is called from main.go before any command is executed.
+func Init(cmd *cobra.Command) {
+	// This function is called by cobra before any command runs, once the flags are parsed.
 	setupConfigPaths()
+
+	if isReadOnlyCommand(cmd) {
+		readOnlyRun = true
+		// The output of commands run on every prompt, cd or tab is captured, so
+		// the note is only shown to a person at the terminal
+		if term.IsTerminal(int(os.Stdout.Fd())) && readLayoutVersion() < currentLayoutVersion {
+			fmt.Fprintf(os.Stderr, "Note: '%s' uses an older layout, which is only migrated by commands that change it (e.g. 'cc-provider setup').\n", cfgDir)
+		}
+		return
+	}
+
+	// A dry run must not change anything, not even by migrating the layout
+	if isDryRun(cmd) {
+		if readLayoutVersion() < currentLayoutVersion {
+			fmt.Fprintf(os.Stderr, "Note: '%s' uses an older layout. The first run without --dry-run moves its files into envs/, shell/ and state/ and updates the paths in ~/.bashrc and ~/.zshrc; these changes are not shown below.\n", cfgDir)
+		}
+		return
+	}
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	if err := ensureLayout(); err != nil {
+		// Removing the shell integration must stay possible whatever state the directory is in
+		if !isUninstallCommand(cmd) {
+			fmt.Fprintf(os.Stderr, "Error preparing config directory '%s': %v\n", cfgDir, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: preparing config directory '%s': %v\n", cfgDir, err)
+	}
+
+	// A directory given on the command line is meant for a single run (sandboxes,
+	// tests), so the shell configuration is left alone; 'setup' still installs it.
+	if rootCmd.PersistentFlags().Changed("config-dir") {
+		return
+	}
+	// After uninstall, the shell integration is only installed again by 'setup'
+	if cmd.Annotations[skipShellSetupAnnotation] != "" || mustLoadSettings().NoShellSetup {
+		return
+	}
 	if _, err := ensureShellConfig(false); err != nil {
 		fmt.Fprintf(os.Stderr, "Error during initial shell setup: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// isDryRun reports whether cmd was asked to only show what it would change.
+func isDryRun(cmd *cobra.Command) bool {
+	f := cmd.Flags().Lookup("dry-run")
+	return f != nil && f.Value.String() == "true"
+}
+
 // setupConfigPaths initializes the configuration directory path and creates it if necessary.
 func setupConfigPaths() {
-	home, err := os.UserHomeDir()
+	dir, err := resolveConfigDir()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error finding home directory: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error finding config directory: %v\n", err)
 		os.Exit(1)
 	}
 
-	cfgDir = filepath.Join(home, ".cc-provider")
-	activeEnvFile = filepath.Join(cfgDir, "active_env.sh")
+	cfgDir = dir
+	envsDir = filepath.Join(cfgDir, "envs")
+	templateDir = filepath.Join(cfgDir, "templates")
+	shellDir = filepath.Join(cfgDir, "shell")
+	stateDir = filepath.Join(cfgDir, "state")
+	activeEnvFile = filepath.Join(shellDir, "active_env.sh")
 
 	if _, err := os.Stat(cfgDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(cfgDir, 0755); err != nil {
@@ -39,12 +121,68 @@ func setupConfigPaths() {
 	}
 }
 
+// configHomeEnv is the environment variable that selects the config directory.
+const configHomeEnv = "CC_PROVIDER_HOME"
+
+// resolveConfigDir returns the configuration directory in effect, in order of precedence:
+//
+//  1. the --config-dir flag
+//  2. $CC_PROVIDER_HOME
+//  3. $XDG_CONFIG_HOME/cc-provider (or ~/.config/cc-provider), if it exists
+//  4. the legacy ~/.cc-provider, if it exists
+//  5. $XDG_CONFIG_HOME/cc-provider if XDG_CONFIG_HOME is set, otherwise ~/.cc-provider
+//
+// 按优先级确定配置目录,并自动识别旧的 ~/.cc-provider 目录
+func resolveConfigDir() (string, error) {
+	for _, dir := range []string{configDirFlag, os.Getenv(configHomeEnv)} {
+		if dir != "" {
+			return filepath.Abs(dir)
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding home directory: %w", err)
+	}
+
+	// The XDG spec says relative values are invalid and must be ignored
+	xdgBase := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(xdgBase) {
+		xdgBase = ""
+	}
+	xdgDir := filepath.Join(home, ".config", "cc-provider")
+	if xdgBase != "" {
+		xdgDir = filepath.Join(xdgBase, "cc-provider")
+	}
+	legacyDir := filepath.Join(home, ".cc-provider")
+
+	if info, err := os.Stat(xdgDir); err == nil && info.IsDir() {
+		return xdgDir, nil
+	}
+	if info, err := os.Stat(legacyDir); err == nil && info.IsDir() {
+		return legacyDir, nil
+	}
+	if xdgBase != "" {
+		return xdgDir, nil
+	}
+	return legacyDir, nil
+}
+
 // ensureShellConfig checks and modifies the user's shell configuration file.
 func ensureShellConfig(forceUpdate bool) (string, error) {
 	shell := os.Getenv("SHELL")
 	var shellType string
 	var rcFileName string
 
+	switch shellBase := filepath.Base(shell); {
+	case strings.Contains(shell, "fish"):
+		return ensureFishConfig(forceUpdate)
+	case strings.Contains(shellBase, "pwsh") || strings.Contains(shellBase, "powershell"):
+		return ensurePwshConfig(forceUpdate)
+	case shellBase == "nu":
+		return ensureNuConfig(forceUpdate)
+	}
+
 	if strings.Contains(shell, "zsh") {
 		shellType = "zsh"
 		rcFileName = ".zshrc"
@@ -62,147 +200,71 @@ func ensureShellConfig(forceUpdate bool) (string, error) {
 	}
 	rcPath := filepath.Join(home, rcFileName)
 
-	// Check if the source line for active_env.sh already exists
-	file, err := os.Open(rcPath)
-	needsSetup := true
-	needsShellFunction := false
-	if err == nil {
-		scanner := bufio.NewScanner(file)
-		sourceLine := fmt.Sprintf("source \"%s\"", activeEnvFile)
-		shellFunctionPath := filepath.Join(cfgDir, "shell_function.sh")
-		shellFunctionSourceLine := fmt.Sprintf("source \"%s\"", shellFunctionPath)
-
-		hasActiveEnv := false
-		hasShellFunction := false
-
-		for scanner.Scan() {
-			line := scanner.Text()
-			if strings.Contains(line, sourceLine) {
-				hasActiveEnv = true
-			}
-			if strings.Contains(line, shellFunctionSourceLine) {
-				hasShellFunction = true
-			}
-		}
-		file.Close()
-
-		needsSetup = !hasActiveEnv
-		needsShellFunction = !hasShellFunction && hasActiveEnv // Only add if already has active_env
-	}
-
 	// Create an empty active_env.sh if it doesn't exist, to prevent source errors on shell startup.
 	if _, err := os.Stat(activeEnvFile); os.IsNotExist(err) {
-		if err := os.WriteFile(activeEnvFile, []byte("# cc-provider active environment script\n"), 0644); err != nil {
+		if err := writeGeneratedScript(activeEnvFile, []byte("# cc-provider active environment script\n"), 0600); err != nil {
 			return "", fmt.Errorf("creating empty active_env.sh: %w", err)
 		}
 	}
 
-	// Always ensure shell function file exists (for upgrades)
-	shellFunctionPath := filepath.Join(cfgDir, "shell_function.sh")
-	if _, err := os.Stat(shellFunctionPath); os.IsNotExist(err) || needsShellFunction || forceUpdate {
-		shellFunctionContent := `# cc-provider shell integration
+	// Always keep the shell function file up to date, for upgrades and so that
+	// it points at the config directory in effect
+	shellFunctionPath := filepath.Join(shellDir, "shell_function.sh")
+	shellFunctionContent := fmt.Sprintf(`# cc-provider shell integration
 # This wraps the cc-provider command to enable immediate activation
 
 cc-provider() {
-    local cmd="$1"
-    [ $# -gt 0 ] && shift
-    
-    if [ "$cmd" = "activate" ]; then
-        # Check for --eval flag
-        local use_eval=false
-        local env_name=""
-        
-        for arg in "$@"; do
-            case "$arg" in
-                --eval|-e)
-                    use_eval=true
-                    ;;
-                *)
-                    env_name="$arg"
-                    ;;
-            esac
-        done
-        
-        if [ "$use_eval" = "true" ]; then
-            # Direct eval mode
-            eval "$(command cc-provider activate --eval "$env_name")"
-        else
-            # Automatic eval mode for immediate activation
-            eval "$(command cc-provider activate --eval "$env_name")"
-        fi
-    else
-        # For all other commands, call the actual binary
-        command cc-provider "$cmd" "$@"
+    # Use the config directory this integration was generated for unless overridden
+    local CC_PROVIDER_HOME=${CC_PROVIDER_HOME:-%s}
+    export CC_PROVIDER_HOME
+
+    # Commands write the updates for the current shell to a directives file,
+    # which is applied once they exit; arguments are passed on unchanged
+    local directives exit_status
+    directives=$(mktemp "${TMPDIR:-/tmp}/cc-provider.XXXXXX") || {
+        command cc-provider "$@"
+        return
+    }
+    CC_PROVIDER_DIRECTIVES=$directives CC_PROVIDER_DIRECTIVES_SHELL=bash command cc-provider "$@"
+    exit_status=$?
+    if [ -s "$directives" ]; then
+        . "$directives"
     fi
+    rm -f "$directives"
+    return $exit_status
 }
-`
-		if err := os.WriteFile(shellFunctionPath, []byte(shellFunctionContent), 0644); err != nil {
-			return "", fmt.Errorf("creating shell function file: %w", err)
-		}
+`, shellQuote(cfgDir))
+	if projectHookEnabled() {
+		shellFunctionContent += posixProjectHook()
 	}
-
-	// If already configured but missing shell function, add it
-	// 如果已配置但缺少 shell 函数,添加它
-	if needsShellFunction {
-		f, err := os.OpenFile(rcPath, os.O_APPEND|os.O_WRONLY, 0644)
-		if err != nil {
-			return "", fmt.Errorf("opening %s for appending: %w", rcPath, err)
-		}
-		defer f.Close()
-
-		shellFunctionSourceLine := fmt.Sprintf("\nsource \"%s\"\n", shellFunctionPath)
-		if _, err := f.WriteString(shellFunctionSourceLine); err != nil {
-			return "", fmt.Errorf("writing to %s: %w", rcPath, err)
-		}
-
-		fmt.Printf("Updated shell configuration in '%s' to add shell function support.\n", rcPath)
-		fmt.Println("Please restart your shell or source your config file to apply changes.")
-		return rcPath, nil
-	}
-
-	// If already fully configured, just return
-	// 如果已完全配置,直接返回
-	if !needsSetup {
-		return rcPath, nil
-	}
-
-	// Full setup for first-time users
-	// 首次用户的完整设置
-	f, err := os.OpenFile(rcPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return "", fmt.Errorf("opening %s for appending: %w", rcPath, err)
+	if err := writeGeneratedScript(shellFunctionPath, []byte(shellFunctionContent), 0644); err != nil {
+		return "", fmt.Errorf("creating shell function file: %w", err)
 	}
-	defer f.Close()
 
 	// Generate and write completion script
-	completionFilePath := filepath.Join(cfgDir, "completion."+shellType)
-	completionFile, err := os.Create(completionFilePath)
-	if err != nil {
-		return "", fmt.Errorf("creating completion file: %w", err)
+	completionFilePath := filepath.Join(shellDir, "completion."+shellType)
+	if _, err := os.Stat(completionFilePath); os.IsNotExist(err) || forceUpdate {
+		var completion bytes.Buffer
+		switch shellType {
+		case "bash":
+			rootCmd.GenBashCompletion(&completion)
+		case "zsh":
+			rootCmd.GenZshCompletion(&completion)
+		}
+		if err := writeGeneratedScript(completionFilePath, completion.Bytes(), 0644); err != nil {
+			return "", fmt.Errorf("creating completion file: %w", err)
+		}
 	}
-	defer completionFile.Close()
 
-	switch shellType {
-	case "bash":
-		rootCmd.GenBashCompletion(completionFile)
-	case "zsh":
-		rootCmd.GenZshCompletion(completionFile)
+	// Source the activation, completion, and shell function scripts from the managed block
+	// 在托管块中加载激活、补全和 shell 函数脚本
+	lines := []string{
+		"source " + shellQuote(activeEnvFile),
+		"source " + shellQuote(completionFilePath),
+		"source " + shellQuote(shellFunctionPath),
 	}
-
-	// Add source commands for activation, completion, and shell function scripts
-	// 添加激活、补全和 shell 函数脚本的 source 命令
-	configBlock := fmt.Sprintf(`
-# Added by cc-provider for environment activation and auto-completion
-source "%s"
-source "%s"
-source "%s"
-`, activeEnvFile, completionFilePath, shellFunctionPath)
-
-	if _, err := f.WriteString(configBlock); err != nil {
-		return "", fmt.Errorf("writing to %s: %w", rcPath, err)
+	if err := ensureManagedBlock(rcPath, lines); err != nil {
+		return "", err
 	}
-
-	fmt.Printf("First-time setup complete. Added configuration to '%s' for automatic environment loading and tab completion.\n", rcPath)
-	fmt.Println("Please restart your shell or source your config file to apply changes.")
 	return rcPath, nil
 }
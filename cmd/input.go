@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// requiredEnvKeys are the variables every environment needs.
+var requiredEnvKeys = []string{"ANTHROPIC_BASE_URL", "ANTHROPIC_AUTH_TOKEN"}
+
+// shouldPrompt reports whether a command asks for values interactively. It
+// does not when --yes or values were given on the command line, or when
+// stdin is not a terminal, so scripts never wait for input.
+// 判断是否交互式提示:给出 --yes 或值标志、或标准输入不是终端时不提示
+func shouldPrompt(yes, valuesGiven bool) bool {
+	return !yes && !valuesGiven && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// readInput reads a line of input. ok is false once the input has ended
+// without a value, so callers can stop asking instead of looping forever.
+func readInput(reader *bufio.Reader) (input string, ok bool) {
+	line, err := reader.ReadString('\n')
+	input = strings.TrimSpace(line)
+	return input, err == nil || input != ""
+}
+
+// exitInputEnded reports that the input ended before a required value was
+// given and exits.
+func exitInputEnded(message string) {
+	fmt.Fprintf(os.Stderr, "\nError: %s is required, but the input has ended.\n", strings.TrimSpace(message))
+	fmt.Fprintln(os.Stderr, "Pass values with flags such as --set KEY=VALUE to run without prompts.")
+	os.Exit(1)
+}
+
+// parseAssignments parses KEY=VALUE arguments given with --set.
+func parseAssignments(assignments []string) ([]EnvVar, error) {
+	var vars []EnvVar
+	for _, assignment := range assignments {
+		key, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid assignment %q (expected KEY=VALUE)", assignment)
+		}
+		if !isValidEnvKey(key) {
+			return nil, fmt.Errorf("invalid environment variable name %q", key)
+		}
+		if slices.Contains(stateKeys(), key) {
+			return nil, fmt.Errorf("%s is managed by cc-provider and cannot be set", key)
+		}
+		if err := validateEnvValue(value); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		vars = append(vars, EnvVar{Key: key, Value: value})
+	}
+	return vars, nil
+}
+
+// mustParseAssignments parses the --set arguments, or exits with an error.
+func mustParseAssignments(assignments []string) []EnvVar {
+	vars, err := parseAssignments(assignments)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return vars
+}
+
+// mustReadTokenStdin reads the auth token given with --token-stdin, or exits
+// with an error. Reading it from stdin keeps it out of the process list and
+// the shell history.
+func mustReadTokenStdin() string {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading token from stdin: %v\n", err)
+		os.Exit(1)
+	}
+	token := strings.TrimRight(string(data), "\r\n")
+	if token == "" || strings.ContainsAny(token, "\r\n") {
+		fmt.Fprintln(os.Stderr, "Error: --token-stdin expects a single line holding the token.")
+		os.Exit(1)
+	}
+	return token
+}
+
+// missingRequiredKeys returns the required variables that vars lacks.
+func missingRequiredKeys(vars map[string]string) []string {
+	var missing []string
+	for _, key := range requiredEnvKeys {
+		if vars[key] == "" {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
@@ -4,17 +4,21 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
 var inspectCmd = &cobra.Command{
-	Use:               "inspect [env-name]",
-	Short:             "Inspects a provider environment's configuration.",
-	Long:              `Displays the configuration of a provider environment. If no name is given, prompts you to select one interactively.`,
+	Use:   "inspect [env-name]",
+	Short: "Inspects a provider environment's configuration.",
+	Long: `Displays the configuration of a provider environment. If no name is given, prompts you to select one interactively.
+With --output json|yaml, secret references are shown as they are, without unlocking the vault.`,
 	Args:              cobra.MaximumNArgs(1),
 	ValidArgsFunction: completeEnvironmentNames,
+	Annotations:       map[string]string{readOnlyAnnotation: "true"},
 	Run:               runInspectCmd,
 }
 
@@ -23,6 +27,10 @@ func runInspectCmd(cmd *cobra.Command, args []string) {
 
 	var envName string
 	if len(args) == 0 {
+		if structuredOutput() {
+			fmt.Fprintf(os.Stderr, "Error: An environment name is required with --output %s.\n", outputFormat)
+			os.Exit(1)
+		}
 		envName = selectEnvironment(reader)
 		if envName == "" {
 			return
@@ -30,18 +38,33 @@ func runInspectCmd(cmd *cobra.Command, args []string) {
 	} else {
 		envName = args[0]
 	}
+	mustValidateEnvName(envName)
 
-	envFilePath := filepath.Join(cfgDir, envName)
-	if _, err := os.Stat(envFilePath); os.IsNotExist(err) {
+	reg := mustLoadRegistry()
+	if !reg.Has(envName) {
 		fmt.Fprintf(os.Stderr, "Error: Environment '%s' not found.\n", envName)
 		os.Exit(1)
 	}
 
-	envVars, err := readEnvFile(envFilePath)
+	resolved, err := resolveEnvironment(envName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading environment '%s': %v\n", envName, err)
 		os.Exit(1)
 	}
+	envVars := resolved.Map()
+
+	if structuredOutput() {
+		details := environmentDetails{
+			environmentInfo: newEnvironmentInfo(reg, envName),
+			ExtendsChain:    append([]string{}, resolved.chain[1:]...),
+			Variables:       newVariableInfos(envVars),
+		}
+		for i := range details.Variables {
+			details.Variables[i].Source = resolved.layers[details.Variables[i].Key]
+		}
+		printStructured(details)
+		return
+	}
 
 	activeEnv := os.Getenv("CC_PROVIDER_ACTIVE_ENV")
 	activeMarker := ""
@@ -49,7 +72,32 @@ func runInspectCmd(cmd *cobra.Command, args []string) {
 		activeMarker = " (active)"
 	}
 
+	// Unlock the vault on demand if the environment references it
+	var v *Vault
+	for _, val := range envVars {
+		if isVaultRef(val) {
+			if v, err = openVault(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: cannot unlock vault: %v\n", err)
+			}
+			break
+		}
+	}
+
 	fmt.Printf("Environment: %s%s\n", envName, activeMarker)
+	if meta := reg.Get(envName); meta != nil {
+		if meta.Description != "" {
+			fmt.Printf("Description: %s\n", meta.Description)
+		}
+		if meta.Template != "" {
+			fmt.Printf("Template:    %s\n", meta.Template)
+		}
+		if len(meta.Tags) > 0 {
+			fmt.Printf("Tags:        %s\n", strings.Join(meta.Tags, ", "))
+		}
+	}
+	if len(resolved.chain) > 1 {
+		fmt.Printf("Extends:     %s\n", strings.Join(resolved.chain[1:], " -> "))
+	}
 	fmt.Println("---")
 	for _, key := range envVarKeys {
 		if key == "CC_PROVIDER_ACTIVE_ENV" {
@@ -57,15 +105,73 @@ func runInspectCmd(cmd *cobra.Command, args []string) {
 		}
 		val, ok := envVars[key]
 		if ok {
-			// Mask token value for safety
-			if key == "ANTHROPIC_AUTH_TOKEN" {
-				val = maskSecret(val)
-			}
-			fmt.Printf("  %-42s %s\n", key+":", val)
+			fmt.Printf("  %-42s %s%s\n", key+":", inspectValue(v, key, val), layerNote(resolved, envName, key))
 		} else {
 			fmt.Printf("  %-42s %s\n", key+":", "(not set)")
 		}
 	}
+
+	// Any other variables the environment sets
+	var extraKeys []string
+	for key := range envVars {
+		if !slices.Contains(envVarKeys, key) {
+			extraKeys = append(extraKeys, key)
+		}
+	}
+	sort.Strings(extraKeys)
+	for _, key := range extraKeys {
+		fmt.Printf("  %-42s %s%s\n", key+":", inspectValue(v, key, envVars[key]), layerNote(resolved, envName, key))
+	}
+}
+
+// layerNote names the environment an inherited value of key came from.
+func layerNote(resolved *resolvedEnv, envName, key string) string {
+	if layer := resolved.layers[key]; layer != envName {
+		return fmt.Sprintf("  (from %s)", layer)
+	}
+	return ""
+}
+
+// inspectValue returns how inspect shows the value of key: secrets are
+// masked and references are shown instead of what they resolve to.
+func inspectValue(v *Vault, key, val string) string {
+	switch {
+	case isVaultRef(val):
+		return describeVaultRef(v, val)
+	case isSecretRef(val):
+		// Show where the secret comes from, never the secret itself
+		return val
+	case isSecretKey(key):
+		// Mask token value for safety
+		return maskSecret(val)
+	}
+	return val
+}
+
+// describeVaultRef shows a vault reference together with its masked value,
+// or only the reference if the vault could not be unlocked.
+func describeVaultRef(v *Vault, ref string) string {
+	if v == nil {
+		return ref
+	}
+	secret, ok := v.Get(strings.TrimPrefix(ref, vaultRefPrefix))
+	if !ok {
+		return ref + " (missing)"
+	}
+	return fmt.Sprintf("%s (%s)", maskSecret(secret), ref)
+}
+
+// isSecretKey reports whether the value of key should be treated as a secret.
+func isSecretKey(key string) bool {
+	if key == "ANTHROPIC_AUTH_TOKEN" || key == "ANTHROPIC_API_KEY" {
+		return true
+	}
+	for _, suffix := range []string{"_TOKEN", "_KEY", "_SECRET", "_PASSWORD"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
 }
 
 // maskSecret shows only the first 6 and last 4 characters of a secret.
@@ -0,0 +1,355 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// currentLayoutVersion is the version of the on-disk layout of the config directory.
+//
+//	envs/       one file per environment
+//	templates/  custom templates
+//	shell/      generated shell scripts (activation, shell function, completion)
+//	state/      registry, vault and other state kept by cc-provider
+//	config.json settings
+//
+// Version 1 is the original flat layout where everything lived in the config directory itself.
+// 配置目录的磁盘布局版本
+const currentLayoutVersion = 2
+
+// legacyShellFiles are the generated scripts of the flat layout.
+var legacyShellFiles = []string{"active_env.sh", "shell_function.sh"}
+
+// legacyStateFiles are the state files of the flat layout.
+var legacyStateFiles = []string{"registry.json", "vault.json", "vault.session"}
+
+// layoutVersionPath returns the path of the file recording the layout version.
+func layoutVersionPath() string {
+	return filepath.Join(stateDir, "layout")
+}
+
+// readLayoutVersion returns the layout version of the config directory.
+// A directory without a version file uses the flat layout of version 1.
+func readLayoutVersion() int {
+	data, err := os.ReadFile(layoutVersionPath())
+	if err != nil {
+		return 1
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 1
+	}
+	return version
+}
+
+// ensureLayout creates the layout directories, migrating an older layout first if needed.
+func ensureLayout() error {
+	if readLayoutVersion() < currentLayoutVersion {
+		if err := migrateLayout(); err != nil {
+			return fmt.Errorf("migrating config directory layout: %w", err)
+		}
+	}
+	for _, dir := range []string{envsDir, templateDir, shellDir, stateDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating directory '%s': %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// migrateLayout moves a flat version 1 config directory into the current layout.
+// Environment files are moved first, through a staging directory, so an
+// environment named like one of the new directories cannot get in the way,
+// and no file is ever deleted: anything that is not recognized stays where it is.
+// 将旧版扁平布局迁移到当前布局,不会丢失任何环境。
+func migrateLayout() error {
+	entries, err := os.ReadDir(cfgDir)
+	if err != nil {
+		return fmt.Errorf("reading config directory: %w", err)
+	}
+
+	// 1. Collect the environment files of the flat layout: everything the old
+	// registry knows about, plus any file that looks like an environment
+	registered := make(map[string]bool)
+	if data, err := os.ReadFile(filepath.Join(cfgDir, "registry.json")); err == nil {
+		var legacy Registry
+		if err := json.Unmarshal(data, &legacy); err == nil {
+			for name := range legacy.Environments {
+				registered[name] = true
+			}
+		}
+	}
+
+	var envNames []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || isConfigFile(name) {
+			continue
+		}
+		if registered[name] || looksLikeEnvFile(filepath.Join(cfgDir, name)) {
+			envNames = append(envNames, name)
+		}
+	}
+
+	// 2. Move them into a staging directory, then into envs/.
+	// Staging directories left behind by an interrupted migration are picked up again.
+	staging, err := os.MkdirTemp(cfgDir, ".migrate-")
+	if err != nil {
+		return fmt.Errorf("creating staging directory: %w", err)
+	}
+	for _, name := range envNames {
+		if err := os.Rename(filepath.Join(cfgDir, name), filepath.Join(staging, name)); err != nil {
+			return fmt.Errorf("moving environment '%s': %w", name, err)
+		}
+	}
+	if err := os.MkdirAll(envsDir, 0755); err != nil {
+		return fmt.Errorf("creating directory '%s': %w", envsDir, err)
+	}
+
+	// Environments whose name is no longer valid get a valid one, as they
+	// could not be used or even renamed otherwise
+	renamed := make(map[string]string)
+	stagingDirs, _ := filepath.Glob(filepath.Join(cfgDir, ".migrate-*"))
+	for _, dir := range stagingDirs {
+		staged, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		// Valid names go first, so a new name never takes one of them
+		slices.SortStableFunc(staged, func(a, b os.DirEntry) int {
+			return boolRank(validateEnvName(a.Name()) != nil) - boolRank(validateEnvName(b.Name()) != nil)
+		})
+		for _, entry := range staged {
+			name := entry.Name()
+			newName := name
+			if validateEnvName(name) != nil {
+				newName = unusedEnvName(name)
+				renamed[name] = newName
+			}
+			if err := migrateFile(filepath.Join(dir, name), filepath.Join(envsDir, newName)); err != nil {
+				return fmt.Errorf("moving environment '%s' (it is kept in '%s'): %w", name, dir, err)
+			}
+			envNames = slices.DeleteFunc(envNames, func(n string) bool { return n == name })
+			envNames = append(envNames, newName)
+		}
+		if err := os.Remove(dir); err != nil {
+			return fmt.Errorf("removing staging directory '%s': %w", dir, err)
+		}
+	}
+
+	// 3. Move generated shell scripts and state
+	for _, dir := range []string{shellDir, stateDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating directory '%s': %w", dir, err)
+		}
+	}
+
+	movedScripts := make(map[string]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(slices.Contains(legacyShellFiles, name) || strings.HasPrefix(name, "completion.")) {
+			continue
+		}
+		oldPath, newPath := filepath.Join(cfgDir, name), filepath.Join(shellDir, name)
+		if err := migrateFile(oldPath, newPath); err != nil {
+			return fmt.Errorf("moving '%s': %w", name, err)
+		}
+		movedScripts[oldPath] = newPath
+	}
+	for _, name := range legacyStateFiles {
+		if err := migrateFile(filepath.Join(cfgDir, name), filepath.Join(stateDir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("moving '%s': %w", name, err)
+		}
+	}
+
+	// 4. Point the shell configuration at the moved scripts
+	if err := rewriteShellConfigPaths(movedScripts); err != nil {
+		return err
+	}
+
+	// 5. Make sure every moved environment is registered, under its new name
+	// if it had to be renamed
+	reg, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	defaultRenamed := ""
+	for oldName, newName := range renamed {
+		if reg.Active == oldName {
+			defaultRenamed = newName
+		}
+		if _, ok := reg.Environments[oldName]; ok {
+			reg.Rename(oldName, newName)
+		}
+	}
+	for _, name := range envNames {
+		if _, ok := reg.Environments[name]; !ok {
+			reg.Add(name, &EnvMeta{})
+		}
+	}
+	if err := reg.save(); err != nil {
+		return err
+	}
+
+	if defaultRenamed != "" {
+		script, err := loadActiveEnvScript(defaultRenamed)
+		if err != nil {
+			return err
+		}
+		if err := writeActiveEnvScript(script); err != nil {
+			return fmt.Errorf("writing active environment script: %w", err)
+		}
+	}
+
+	if err := writeFileAtomic(layoutVersionPath(), []byte(strconv.Itoa(currentLayoutVersion)+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing layout version: %w", err)
+	}
+
+	if len(envNames) > 0 {
+		fmt.Fprintf(os.Stderr, "Migrated %d environment(s) to '%s'.\n", len(envNames), envsDir)
+	}
+	for _, oldName := range slices.Sorted(maps.Keys(renamed)) {
+		fmt.Fprintf(os.Stderr, "Renamed environment '%s' to '%s': %v\n", oldName, renamed[oldName], validateEnvName(oldName))
+	}
+	return nil
+}
+
+// boolRank orders false before true.
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// unusedEnvName derives a valid environment name from name that is not taken
+// in the envs directory, for environments whose name is no longer accepted.
+func unusedEnvName(name string) string {
+	base := strings.Map(func(r rune) rune {
+		if r < 128 && (r == '.' || r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '-'
+	}, name)
+	base = strings.TrimLeft(base, ".-_")
+	if len(base) > 56 {
+		base = base[:56]
+	}
+	if base == "" || validateEnvName(base) != nil {
+		base = strings.TrimRight(base, ".") + "-env"
+		base = strings.TrimLeft(base, "-")
+	}
+
+	candidate := base
+	for i := 2; ; i++ {
+		if validateEnvName(candidate) == nil {
+			if _, err := os.Lstat(environmentPath(candidate)); os.IsNotExist(err) {
+				return candidate
+			}
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// migrateFile moves oldPath to newPath, so that a migration can always
+// finish. A file already at newPath with the same content, as an interrupted
+// migration leaves behind, is kept and oldPath removed. A different file at
+// newPath is kept too, and oldPath is moved next to it under a free name
+// ending in .migrated, so nothing is lost.
+// 迁移单个文件:目标相同则跳过,目标冲突则改名放在旁边
+func migrateFile(oldPath, newPath string) error {
+	if _, err := os.Stat(oldPath); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(newPath); os.IsNotExist(err) {
+		return os.Rename(oldPath, newPath)
+	} else if err != nil {
+		return err
+	}
+	if sameFileContent(oldPath, newPath) {
+		return os.Remove(oldPath)
+	}
+
+	aside := newPath + ".migrated"
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(aside); os.IsNotExist(err) {
+			break
+		}
+		aside = fmt.Sprintf("%s.migrated-%d", newPath, i)
+	}
+	fmt.Fprintf(os.Stderr, "Warning: '%s' already exists; moved '%s' to '%s' instead.\n", newPath, oldPath, aside)
+	return os.Rename(oldPath, aside)
+}
+
+// sameFileContent reports whether the regular files a and b hold the same bytes.
+func sameFileContent(a, b string) bool {
+	dataA, err := os.ReadFile(a)
+	if err != nil {
+		return false
+	}
+	dataB, err := os.ReadFile(b)
+	return err == nil && bytes.Equal(dataA, dataB)
+}
+
+// rewriteShellConfigPaths replaces references to moved scripts in the shell
+// configuration files cc-provider may have edited.
+func rewriteShellConfigPaths(moved map[string]string) error {
+	if len(moved) == 0 {
+		return nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("finding home directory: %w", err)
+	}
+
+	for _, rcFileName := range []string{".zshrc", ".bashrc"} {
+		rcPath := filepath.Join(home, rcFileName)
+		content, err := os.ReadFile(rcPath)
+		if err != nil {
+			continue
+		}
+
+		updated := string(content)
+		for oldPath, newPath := range moved {
+			updated = strings.ReplaceAll(updated, fmt.Sprintf("\"%s\"", oldPath), fmt.Sprintf("\"%s\"", newPath))
+		}
+		if updated == string(content) {
+			continue
+		}
+
+		if err := writeFileAtomic(rcPath, []byte(updated), fileModeOr(rcPath, 0644)); err != nil {
+			return fmt.Errorf("updating %s: %w", rcPath, err)
+		}
+	}
+	return nil
+}
+
+// isConfigFile reports whether fileName is a file the flat layout kept in the
+// config directory for itself rather than an environment.
+func isConfigFile(fileName string) bool {
+	return slices.Contains(legacyShellFiles, fileName) ||
+		slices.Contains(legacyStateFiles, fileName) ||
+		strings.HasPrefix(fileName, "completion.")
+}
+
+// looksLikeEnvFile reports whether the file at path holds an environment.
+// Hidden files, editor backups and files without any variable are skipped.
+func looksLikeEnvFile(path string) bool {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return false
+	}
+	if slices.Contains(ignoredEnvFileExts, filepath.Ext(name)) {
+		return false
+	}
+
+	doc, err := readEnvDocument(path)
+	return err == nil && len(doc.Keys()) > 0
+}
@@ -3,36 +3,40 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var listLong bool // 是否显示元数据 / Whether to show metadata
+
 // listCmd represents the list command
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "Lists all available provider environments.",
-	Long:  `Lists all provider environments configured in the ~/.cc-provider directory. The active environment is marked with an asterisk (*).`,
+	Long: `Lists all provider environments configured in the config directory. The active environment is marked with an asterisk (*).
+Use --long to show each environment's metadata, or --output json|yaml for output meant for scripts.`,
+	Annotations: map[string]string{readOnlyAnnotation: "true"},
 	Run: func(cmd *cobra.Command, args []string) {
 		activeEnv := os.Getenv("CC_PROVIDER_ACTIVE_ENV")
 
-		files, err := os.ReadDir(cfgDir)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading config directory '%s'\n", cfgDir)
-			os.Exit(1)
+		reg := mustLoadRegistry()
+		envs := reg.Names()
+		for name := range reg.Environments {
+			if err := validateEnvName(name); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: ignoring environment: %v\n", err)
+			}
 		}
 
-		var envs []string
-		for _, file := range files {
-			fileName := file.Name()
-			// 过滤掉系统文件 / Filter out system files
-			if !file.IsDir() &&
-				fileName != filepath.Base(activeEnvFile) &&
-				!strings.HasPrefix(fileName, "completion.") &&
-				fileName != "shell_function.sh" {
-				envs = append(envs, fileName)
+		if structuredOutput() {
+			list := environmentList{Environments: []environmentInfo{}}
+			for _, env := range envs {
+				list.Environments = append(list.Environments, newEnvironmentInfo(reg, env))
 			}
+			printStructured(list)
+			return
 		}
 
 		if len(envs) == 0 {
@@ -40,6 +44,11 @@ var listCmd = &cobra.Command{
 			return
 		}
 
+		if listLong {
+			printEnvironmentTable(reg, envs, activeEnv)
+			return
+		}
+
 		fmt.Println("Available provider environments: ")
 		for _, env := range envs {
 			if env == activeEnv {
@@ -51,6 +60,54 @@ var listCmd = &cobra.Command{
 	},
 }
 
+// printEnvironmentTable prints the environments with their metadata.
+// 以表格形式打印环境及其元数据
+func printEnvironmentTable(reg *Registry, envs []string, activeEnv string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tTEMPLATE\tTAGS\tCREATED\tMODIFIED\tLAST ACTIVATED\tDESCRIPTION")
+	for _, env := range envs {
+		meta := reg.Get(env)
+		marker := " "
+		if env == activeEnv {
+			marker = "*"
+		}
+
+		lastActivated := "-"
+		if meta.LastActivated != nil {
+			lastActivated = formatListTime(*meta.LastActivated)
+		}
+
+		fmt.Fprintf(w, "%s %s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			marker,
+			env,
+			orDash(meta.Template),
+			orDash(strings.Join(meta.Tags, ",")),
+			formatListTime(meta.CreatedAt),
+			formatListTime(meta.ModifiedAt),
+			lastActivated,
+			orDash(meta.Description),
+		)
+	}
+	w.Flush()
+}
+
+// formatListTime formats a timestamp in local time for the list table.
+func formatListTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// orDash returns s, or "-" if s is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().BoolVarP(&listLong, "long", "l", false, "Show metadata for each environment")
 }
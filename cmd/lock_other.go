@@ -0,0 +1,8 @@
+//go:build !unix
+
+package cmd
+
+// lockConfig is a no-op on platforms without flock.
+func lockConfig() (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockConfig takes an exclusive advisory lock on the config directory so that
+// concurrent cc-provider processes serialize their changes. It waits for the
+// lock if another process holds it. The lock is released by the returned
+// function, or by the kernel when the process exits.
+// 对配置目录加建议锁,使并发的 cc-provider 进程串行修改
+func lockConfig() (func(), error) {
+	f, err := os.OpenFile(lockPath(), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		fmt.Fprintln(os.Stderr, "Waiting for another cc-provider process to finish...")
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
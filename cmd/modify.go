@@ -2,19 +2,36 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"os"
-	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	modifyDescription string   // 新的环境描述 / New environment description
+	modifyTags        []string // 新的环境标签 / New environment tags
+	modifySet         []string // 要设置的变量 / Variables to set, as KEY=VALUE
+	modifyUnset       []string // 要删除的变量 / Variables to remove
+	modifyTokenStdin  bool     // 从标准输入读取令牌 / Read the auth token from stdin
+	modifyYes         bool     // 不提示 / Do not prompt
+)
+
 // modifyCmd represents the modify command
 var modifyCmd = &cobra.Command{
-	Use:               "modify [env-name]",
-	Short:             "Interactively modifies an existing provider environment.",
-	Long:              `Interactively prompts for the necessary details to modify an existing provider environment file in the ~/.cc-provider directory.`,
+	Use:   "modify [env-name]",
+	Short: "Interactively modifies an existing provider environment.",
+	Long: `Interactively prompts for the necessary details to modify an existing provider environment file in the config directory.
+
+Changes can also be given on the command line, which skips the prompts:
+
+  cc-provider modify deepseek --set ANTHROPIC_MODEL=deepseek-chat --unset CLAUDE_CODE_EFFORT_LEVEL
+  cc-provider modify deepseek --token-stdin < token.txt
+
+Without a terminal, or with --yes, nothing is asked either.`,
 	Args:              cobra.MaximumNArgs(1),
 	ValidArgsFunction: completeEnvironmentNamesForModify,
 	Run:               runModifyCmd,
@@ -22,9 +39,15 @@ var modifyCmd = &cobra.Command{
 
 func runModifyCmd(cmd *cobra.Command, args []string) {
 	reader := bufio.NewReader(os.Stdin)
+	interactive := shouldPrompt(modifyYes, len(modifySet) > 0 || len(modifyUnset) > 0 || modifyTokenStdin)
+	assignments := mustParseAssignments(modifySet)
 
 	var envName string
 	// 如果没有提供环境名称,列出可用环境供用户选择 / If no environment name is provided, list available environments for user selection
+	if len(args) == 0 && !interactive {
+		fmt.Fprintln(os.Stderr, "Error: An environment name is required: cc-provider modify <env-name>")
+		os.Exit(1)
+	}
 	if len(args) == 0 {
 		envName = selectEnvironment(reader)
 		if envName == "" {
@@ -33,125 +56,201 @@ func runModifyCmd(cmd *cobra.Command, args []string) {
 	} else {
 		envName = args[0]
 	}
+	mustValidateEnvName(envName)
+	envFilePath := environmentPath(envName)
+
+	// Read the token first, so the lock is not held while waiting for stdin
+	var token string
+	if modifyTokenStdin {
+		token = mustReadTokenStdin()
+	}
+
+	// Changes given on the command line are made under the lock, so that no
+	// concurrent change is lost. The prompts run without it; the file is
+	// checked again under the lock before it is written. Either way, the
+	// vault is opened before the lock is taken.
+	var v *Vault
+	if !interactive {
+		changes := slices.Clone(assignments)
+		if modifyTokenStdin {
+			changes = append(changes, EnvVar{Key: "ANTHROPIC_AUTH_TOKEN", Value: token})
+		}
+		v = mustOpenVaultIf(vaultNeededFor(envName, changes, ""))
 
-	envFilePath := filepath.Join(cfgDir, envName)
+		unlock := mustLockConfig()
+		defer unlock()
+	}
 
 	// 验证环境是否存在 / Validate environment exists
-	if _, err := os.Stat(envFilePath); os.IsNotExist(err) {
+	if !mustLoadRegistry().Has(envName) {
 		fmt.Fprintf(os.Stderr, "Error: Environment '%s' not found.\n", envName)
 		os.Exit(1)
 	}
 
-	// 读取现有环境变量 / Read existing environment variables
-	existingVars, err := readEnvFile(envFilePath)
+	// 读取现有环境文件 / Read the existing environment file
+	original, err := os.ReadFile(envFilePath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading environment file '%s': %v\n", envFilePath, err)
 		os.Exit(1)
 	}
+	doc := ParseEnvFile(string(original))
+	migrateEnvDocument(doc)
 
-	fmt.Printf("\nModifying environment '%s'...\n", envName)
-	fmt.Println("Press Enter to keep current value, or enter new value to update.")
-
-	var envVars []string
+	// 继承的值作为当前值显示,未修改时不写入 / Inherited values are shown as current and only written when changed
+	inherited, err := inheritedValues(baseEnvName(doc))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving environment '%s': %v\n", envName, err)
+		os.Exit(1)
+	}
+	if interactive {
+		promptModifications(reader, envName, doc, inherited)
+		v = mustOpenVaultIf(vaultNeeded(doc, ""))
+
+		unlock := mustLockConfig()
+		defer unlock()
+		current, err := os.ReadFile(envFilePath)
+		if err != nil || !bytes.Equal(current, original) || !mustLoadRegistry().Has(envName) {
+			fmt.Fprintf(os.Stderr, "Error: Environment '%s' was changed by another command while you were editing it. Nothing was saved; run modify again.\n", envName)
+			os.Exit(1)
+		}
+	} else {
+		// 命令行给出的修改 / Changes given on the command line
+		for _, key := range modifyUnset {
+			if !doc.Unset(key) {
+				fmt.Fprintf(os.Stderr, "Warning: %s is not set in environment '%s'.\n", key, envName)
+			}
+		}
+		for _, v := range assignments {
+			doc.Set(v.Key, v.Value)
+		}
+		if modifyTokenStdin {
+			doc.Set("ANTHROPIC_AUTH_TOKEN", token)
+		}
+		mustHaveRequiredKeys(envName, doc)
+	}
 
-	// 必填项 / Required inputs
-	fmt.Println("\nRequired variables: ")
-	baseURL := promptWithExisting(reader, "  ANTHROPIC_BASE_URL", existingVars["ANTHROPIC_BASE_URL"], true)
-	envVars = append(envVars, "ANTHROPIC_BASE_URL="+baseURL)
+	// 更新元数据 / Update metadata
+	mustSaveEnvironment(v, envName, doc, func(meta *EnvMeta) {
+		if cmd.Flags().Changed("description") {
+			meta.Description = modifyDescription
+		}
+		if cmd.Flags().Changed("tag") {
+			meta.Tags = modifyTags
+		}
+	})
 
-	authToken := promptWithExisting(reader, "  ANTHROPIC_AUTH_TOKEN", existingVars["ANTHROPIC_AUTH_TOKEN"], true)
-	envVars = append(envVars, "ANTHROPIC_AUTH_TOKEN="+authToken)
+	fmt.Printf("\nSuccessfully modified environment '%s'.\n", envName)
 
-	// 推荐项 / Recommended inputs
-	fmt.Println("\nRecommended variables (press Enter to keep current or clear): ")
-	model := promptWithExisting(reader, "  ANTHROPIC_MODEL", existingVars["ANTHROPIC_MODEL"], false)
-	if model != "" {
-		envVars = append(envVars, "ANTHROPIC_MODEL="+model)
+	// 将修改应用到使用该环境的默认脚本和当前 shell / Apply the changes to the default scripts and the current shell
+	if err := refreshEnvironment(envName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying changes: %v\n", err)
+		os.Exit(1)
 	}
+}
 
-	haikuModel := promptWithExisting(reader, "  ANTHROPIC_DEFAULT_HAIKU_MODEL", existingVars["ANTHROPIC_DEFAULT_HAIKU_MODEL"], false)
-	if haikuModel != "" {
-		envVars = append(envVars, "ANTHROPIC_DEFAULT_HAIKU_MODEL="+haikuModel)
+// mustHaveRequiredKeys exits with an error if the edited doc of envName,
+// with the environments it extends, lacks a required variable.
+func mustHaveRequiredKeys(envName string, doc *EnvFile) {
+	effective := doc.Map()
+	// The base may have changed with the edit, so it is resolved again
+	inherited, err := inheritedValues(baseEnvName(doc))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving environment '%s': %v\n", envName, err)
+		os.Exit(1)
 	}
-
-	sonnetModel := promptWithExisting(reader, "  ANTHROPIC_DEFAULT_SONNET_MODEL", existingVars["ANTHROPIC_DEFAULT_SONNET_MODEL"], false)
-	if sonnetModel != "" {
-		envVars = append(envVars, "ANTHROPIC_DEFAULT_SONNET_MODEL="+sonnetModel)
+	for key, value := range inherited {
+		if _, ok := effective[key]; !ok {
+			effective[key] = value
+		}
 	}
-
-	opusModel := promptWithExisting(reader, "  ANTHROPIC_DEFAULT_OPUS_MODEL", existingVars["ANTHROPIC_DEFAULT_OPUS_MODEL"], false)
-	if opusModel != "" {
-		envVars = append(envVars, "ANTHROPIC_DEFAULT_OPUS_MODEL="+opusModel)
+	if missing := missingRequiredKeys(effective); len(missing) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: Environment '%s' would lack required variables: %s.\n", envName, strings.Join(missing, ", "))
+		os.Exit(1)
 	}
+}
 
-	subagentModel := promptWithExisting(reader, "  CLAUDE_CODE_SUBAGENT_MODEL", existingVars["CLAUDE_CODE_SUBAGENT_MODEL"], false)
-	if subagentModel != "" {
-		envVars = append(envVars, "CLAUDE_CODE_SUBAGENT_MODEL="+subagentModel)
+// mustSaveEnvironment writes the edited doc of envName, keeping secrets in
+// the vault if there is one, lets updateMeta change its metadata and records
+// the change in its history. The caller must hold the config lock, and pass
+// the vault it opened before taking it, see mustOpenVaultIf.
+// 保存修改后的环境文件并记录历史
+func mustSaveEnvironment(v *Vault, envName string, doc *EnvFile, updateMeta func(meta *EnvMeta)) {
+	recordHistoryBaseline(envHistory, envName)
+
+	// 如果已初始化保险库,将密钥存入其中 / Keep secrets in the vault if one has been initialized
+	if _, _, err := storeSecretsInVault(v, envName, doc); err != nil {
+		fmt.Fprintf(os.Stderr, "Error storing secrets in vault: %v\n", err)
+		os.Exit(1)
 	}
 
-	effortLevel := promptWithExisting(reader, "  CLAUDE_CODE_EFFORT_LEVEL", existingVars["CLAUDE_CODE_EFFORT_LEVEL"], false)
-	if effortLevel != "" {
-		envVars = append(envVars, "CLAUDE_CODE_EFFORT_LEVEL="+effortLevel)
+	// 写入文件,保留注释和自定义变量 / Write to file, keeping comments and custom variables
+	envFilePath := environmentPath(envName)
+	if err := writeEnvDocument(envFilePath, doc); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing environment file '%s': %v\n", envFilePath, err)
+		os.Exit(1)
 	}
 
-	// 可选项(带默认值) / Optional inputs with defaults
-	fmt.Println("\nOptional variables: ")
-	timeout := promptWithExisting(reader, "  API_TIMEOUT_MS", existingVars["API_TIMEOUT_MS"], false)
-	if timeout == "" {
-		timeout = "600000"
+	err := updateRegistry(func(r *Registry) {
+		r.Touch(envName)
+		if updateMeta != nil {
+			updateMeta(r.Get(envName))
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating environment registry: %v\n", err)
+		os.Exit(1)
 	}
-	envVars = append(envVars, "API_TIMEOUT_MS="+timeout)
+	recordHistory(envHistory, envName, "modify")
+}
 
-	disableTraffic := promptWithExisting(reader, "  CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC", existingVars["CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC"], false)
-	if disableTraffic == "" {
-		disableTraffic = "1"
+// promptModifications asks for new values of the required, recommended and
+// optional variables. Inherited values are shown as current and only written
+// to doc when they are changed.
+func promptModifications(reader *bufio.Reader, envName string, doc *EnvFile, inherited map[string]string) {
+	existingVars := doc.Map()
+	for key, value := range inherited {
+		if _, ok := existingVars[key]; !ok {
+			existingVars[key] = value
+		}
 	}
-	envVars = append(envVars, "CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC="+disableTraffic)
-
-	// 写入文件 / Write to file
-	var finalEnvVars []string
-	for _, envVar := range envVars {
-		if parts := strings.SplitN(envVar, "=", 2); len(parts) == 2 {
-			finalEnvVars = append(finalEnvVars, fmt.Sprintf("%s=\"%s\"", parts[0], parts[1]))
-		} else {
-			finalEnvVars = append(finalEnvVars, envVar)
+	set := func(key, value string) {
+		if doc.Has(key) || value != inherited[key] {
+			doc.Set(key, value)
 		}
 	}
 
-	content := strings.Join(finalEnvVars, "\n")
-	if err := os.WriteFile(envFilePath, []byte(content), 0644); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing environment file '%s': %v\n", envFilePath, err)
-		os.Exit(1)
+	fmt.Printf("\nModifying environment '%s'...\n", envName)
+	fmt.Println("Press Enter to keep current value, or enter new value to update.")
+
+	// 必填项 / Required inputs
+	fmt.Println("\nRequired variables: ")
+	for _, key := range requiredEnvKeys {
+		set(key, promptWithExisting(reader, "  "+key, existingVars[key], true))
 	}
 
-	fmt.Printf("\nSuccessfully modified environment '%s'.\n", envName)
+	// 推荐项 / Recommended inputs
+	fmt.Println("\nRecommended variables (press Enter to keep current or clear): ")
+	for _, key := range recommendedEnvKeys {
+		if value := promptWithExisting(reader, "  "+key, existingVars[key], false); value != "" {
+			set(key, value)
+		}
+	}
 
-	// 检查是否是当前激活的环境 / Check if this is the currently active environment
-	if activeEnv := os.Getenv("CC_PROVIDER_ACTIVE_ENV"); activeEnv == envName {
-		fmt.Println("Note: This is the currently active environment.")
-		fmt.Printf("To apply changes, run: cc-provider activate %s\n", envName)
+	// 可选项(带默认值) / Optional inputs with defaults
+	fmt.Println("\nOptional variables: ")
+	for _, opt := range optionalEnvDefaults {
+		value := promptWithExisting(reader, "  "+opt.Key, existingVars[opt.Key], false)
+		if value == "" {
+			value = opt.Value
+		}
+		set(opt.Key, value)
 	}
 }
 
 // selectEnvironment lists available environments and prompts user to select one
 // 列出可用环境并提示用户选择
 func selectEnvironment(reader *bufio.Reader) string {
-	entries, err := os.ReadDir(cfgDir)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading config directory: %v\n", err)
-		os.Exit(1)
-	}
-
-	var envs []string
-	for _, entry := range entries {
-		name := entry.Name()
-		if !entry.IsDir() &&
-			name != "active_env.sh" &&
-			!strings.HasPrefix(name, "completion.") &&
-			name != "shell_function.sh" {
-			envs = append(envs, name)
-		}
-	}
+	envs := getEnvironmentNames()
 
 	if len(envs) == 0 {
 		fmt.Println("No environments found. Use 'cc-provider create' to create one.")
@@ -179,36 +278,10 @@ func selectEnvironment(reader *bufio.Reader) string {
 	return envs[selection-1]
 }
 
-// readEnvFile reads an environment file and returns a map of key-value pairs
-// 读取环境文件并返回键值对映射
-func readEnvFile(filePath string) (map[string]string, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, err
-	}
-
-	envVars := make(map[string]string)
-	scanner := bufio.NewScanner(strings.NewReader(string(content)))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
-			key := parts[0]
-			value := strings.Trim(parts[1], `"`)
-
-			// Migrate ANTHROPIC_SMALL_FAST_MODEL to ANTHROPIC_DEFAULT_HAIKU_MODEL
-			if key == "ANTHROPIC_SMALL_FAST_MODEL" {
-				key = "ANTHROPIC_DEFAULT_HAIKU_MODEL"
-			}
-
-			envVars[key] = value
-		}
-	}
-
-	return envVars, scanner.Err()
+// migrateEnvDocument renames deprecated keys in place.
+// Migrate ANTHROPIC_SMALL_FAST_MODEL to ANTHROPIC_DEFAULT_HAIKU_MODEL
+func migrateEnvDocument(doc *EnvFile) {
+	doc.Rename("ANTHROPIC_SMALL_FAST_MODEL", "ANTHROPIC_DEFAULT_HAIKU_MODEL")
 }
 
 // promptWithExisting prompts user with existing value as default
@@ -220,13 +293,15 @@ func promptWithExisting(reader *bufio.Reader, message, existingValue string, req
 		fmt.Printf("%s: ", message)
 	}
 
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(input)
+	input, ok := readInput(reader)
 
 	// 如果用户输入为空 / If user input is empty
 	if input == "" {
 		// 如果是必填项且没有现有值,继续提示 / If required and no existing value, continue prompting
 		if required && existingValue == "" {
+			if !ok {
+				exitInputEnded(message)
+			}
 			fmt.Println("This field is required.")
 			return promptWithExisting(reader, message, existingValue, required)
 		}
@@ -248,4 +323,10 @@ func completeEnvironmentNamesForModify(cmd *cobra.Command, args []string, toComp
 
 func init() {
 	rootCmd.AddCommand(modifyCmd)
+	modifyCmd.Flags().StringVar(&modifyDescription, "description", "", "Replace the description of the environment")
+	modifyCmd.Flags().StringSliceVar(&modifyTags, "tag", nil, "Replace the tags of the environment (repeatable)")
+	modifyCmd.Flags().StringArrayVar(&modifySet, "set", nil, "Set a variable, as KEY=VALUE (repeatable)")
+	modifyCmd.Flags().StringSliceVar(&modifyUnset, "unset", nil, "Remove a variable (repeatable)")
+	modifyCmd.Flags().BoolVar(&modifyTokenStdin, "token-stdin", false, "Read ANTHROPIC_AUTH_TOKEN from stdin")
+	modifyCmd.Flags().BoolVarP(&modifyYes, "yes", "y", false, "Do not prompt")
 }
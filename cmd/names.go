@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// namePattern is the set of names allowed for environments and templates: a
+// letter or digit followed by letters, digits, dots, dashes and underscores,
+// at most 64 characters. Names never contain a path separator, so they cannot
+// point outside the config directory.
+// 环境和模板名称允许的字符集
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// reservedNames are names of files and directories cc-provider uses itself.
+// They are compared case-insensitively, as many file systems do.
+var reservedNames = []string{
+	"envs", "templates", "shell", "state", "history", "layout",
+	"active_env.sh", "shell_function.sh", "completion.bash", "completion.zsh",
+	"registry.json", "vault.json", "vault.session",
+}
+
+// ignoredEnvFileExts are extensions of files in the envs directory that are
+// never taken for an environment, such as editor backups.
+var ignoredEnvFileExts = []string{".swp", ".swo", ".bak", ".orig", ".tmp", ".json"}
+
+// validateName checks that name is safe to use as the file name of an
+// environment or template; what names the kind of object for the error.
+func validateName(what, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s name must not be empty", what)
+	}
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid %s name '%s': use up to 64 letters, digits, '.', '-' or '_', starting with a letter or digit", what, name)
+	}
+	for _, reserved := range reservedNames {
+		if strings.EqualFold(name, reserved) {
+			return fmt.Errorf("%s name '%s' is reserved", what, name)
+		}
+	}
+	if slices.Contains(ignoredEnvFileExts, strings.ToLower(filepath.Ext(name))) {
+		return fmt.Errorf("%s name '%s' must not end in '%s'", what, name, filepath.Ext(name))
+	}
+	return nil
+}
+
+// validateEnvName checks an environment name, see validateName.
+func validateEnvName(name string) error {
+	return validateName("environment", name)
+}
+
+// validateTemplateName checks a template name, see validateName.
+func validateTemplateName(name string) error {
+	return validateName("template", name)
+}
+
+// mustValidateEnvName exits with an error if name is not a valid environment name.
+// 校验环境名称,无效时退出
+func mustValidateEnvName(name string) {
+	if err := validateEnvName(name); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// mustValidateTemplateName exits with an error if name is not a valid template name.
+func mustValidateTemplateName(name string) {
+	if err := validateTemplateName(name); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// validNames returns the names that pass validate, dropping the others.
+func validNames(names []string, validate func(string) error) []string {
+	var valid []string
+	for _, name := range names {
+		if validate(name) == nil {
+			valid = append(valid, name)
+		}
+	}
+	return valid
+}
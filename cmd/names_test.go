@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateEnvName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr string
+	}{
+		// Names that must be accepted
+		{name: "deepseek"},
+		{name: "a"},
+		{name: "7"},
+		{name: "deepseek-fast"},
+		{name: "glm_4.6"},
+		{name: "Work.Client-2"},
+		{name: strings.Repeat("a", 64)},
+		{name: "templates2"},
+		{name: "my-state"},
+
+		// Empty and over-long names
+		{name: "", wantErr: "must not be empty"},
+		{name: strings.Repeat("a", 65), wantErr: "invalid environment name"},
+
+		// Path traversal and separators
+		{name: ".", wantErr: "invalid environment name"},
+		{name: "..", wantErr: "invalid environment name"},
+		{name: "../x", wantErr: "invalid environment name"},
+		{name: "a/b", wantErr: "invalid environment name"},
+		{name: `a\b`, wantErr: "invalid environment name"},
+		{name: "/etc/passwd", wantErr: "invalid environment name"},
+		{name: ".hidden", wantErr: "invalid environment name"},
+		{name: "-flag", wantErr: "invalid environment name"},
+		{name: "my env", wantErr: "invalid environment name"},
+		{name: "a\nb", wantErr: "invalid environment name"},
+
+		// Reserved names, in any case
+		{name: "templates", wantErr: "is reserved"},
+		{name: "Templates", wantErr: "is reserved"},
+		{name: "envs", wantErr: "is reserved"},
+		{name: "shell", wantErr: "is reserved"},
+		{name: "STATE", wantErr: "is reserved"},
+		{name: "history", wantErr: "is reserved"},
+		{name: "layout", wantErr: "is reserved"},
+		{name: "active_env.sh", wantErr: "is reserved"},
+		{name: "Active_Env.SH", wantErr: "is reserved"},
+		{name: "shell_function.sh", wantErr: "is reserved"},
+		{name: "registry.json", wantErr: "is reserved"},
+		{name: "vault.session", wantErr: "is reserved"},
+
+		// Extensions of files that are never taken for an environment
+		{name: "config.json", wantErr: "must not end in '.json'"},
+		{name: "deepseek.bak", wantErr: "must not end in '.bak'"},
+		{name: "deepseek.SWP", wantErr: "must not end in '.SWP'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEnvName(tt.name)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateEnvName(%q) = %v, want nil", tt.name, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("validateEnvName(%q) = nil, want error containing %q", tt.name, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateEnvName(%q) = %v, want error containing %q", tt.name, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTemplateNameNamesKind(t *testing.T) {
+	err := validateTemplateName("../x")
+	if err == nil || !strings.Contains(err.Error(), "invalid template name") {
+		t.Fatalf("validateTemplateName(%q) = %v, want an invalid template name error", "../x", err)
+	}
+}
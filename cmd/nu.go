@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// nuQuote quotes s for nushell. Single-quoted strings expand nothing but
+// cannot hold a single quote; such values use a raw string, r#'...'#, with
+// enough # that s cannot close it early.
+// 为 nushell 安全地引用字符串
+func nuQuote(s string) string {
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	hashes := "#"
+	for strings.Contains(s, "'"+hashes) {
+		hashes += "#"
+	}
+	return "r" + hashes + "'" + s + "'" + hashes
+}
+
+// nuDialect activates with hide-env and load-env. nushell cannot evaluate
+// generated code at runtime, so its eval output is a JSON record
+// {"unset": [...], "set": {...}, "message": "..."} that the wrapper function applies.
+var nuDialect shellDialect = nuShellDialect{}
+
+type nuShellDialect struct{}
+
+func (nuShellDialect) scriptExt() string { return ".nu" }
+
+func (nuShellDialect) file(s *activationScript) string {
+	var sb strings.Builder
+
+	sb.WriteString("# Unset previous variables managed by cc-provider\n")
+	sb.WriteString(fmt.Sprintf("hide-env --ignore-errors ...(if '%[1]s' in $env { $env.%[1]s | split row ' ' | where {|key| $key != ''} } else { [%[2]s] })\n",
+		managedKeysEnv, strings.Join(fallbackKeys(), " ")))
+	for _, key := range stateKeys() {
+		sb.WriteString(fmt.Sprintf("hide-env --ignore-errors %s\n", key))
+	}
+	sb.WriteString("\n")
+
+	if s.envName != "" {
+		sb.WriteString(fmt.Sprintf("# Export variables for environment: %s\n", strings.ReplaceAll(s.envName, "\n", " ")))
+		sb.WriteString("load-env {\n")
+		for _, v := range s.vars {
+			if s.isDeferred(v.Key) {
+				sb.WriteString(fmt.Sprintf("    %s: (^cc-provider %s | str trim --right --char \"\\n\")\n", v.Key, quoteEach(resolveArgs(s.envName, v.Key), nuQuote)))
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("    %s: %s\n", v.Key, nuQuote(v.Value)))
+		}
+		sb.WriteString(fmt.Sprintf("    CC_PROVIDER_ACTIVE_ENV: %s\n", nuQuote(s.envName)))
+		sb.WriteString(fmt.Sprintf("    %s: %s\n", managedKeysEnv, nuQuote(s.recordedKeys())))
+		sb.WriteString("}\n")
+	}
+	return sb.String()
+}
+
+func (nuShellDialect) eval(s *activationScript) string {
+	activation := struct {
+		Unset   []string          `json:"unset"`
+		Set     map[string]string `json:"set"`
+		Message string            `json:"message"`
+	}{Unset: append([]string{}, s.unset...), Set: map[string]string{}, Message: s.message}
+	if s.envName != "" {
+		activation.Set["CC_PROVIDER_ACTIVE_ENV"] = s.envName
+		activation.Set[managedKeysEnv] = s.recordedKeys()
+	}
+	for _, v := range s.vars {
+		activation.Set[v.Key] = v.Value
+	}
+
+	data, _ := json.Marshal(activation)
+	return string(data) + "\n"
+}
+
+// nuConfigPath returns the config.nu nushell loads at startup.
+func nuConfigPath() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("finding config directory: %w", err)
+	}
+	return filepath.Join(base, "nushell", "config.nu"), nil
+}
+
+// nuShellFunction returns the nushell wrapper that enables immediate activation.
+func nuShellFunction() string {
+	return fmt.Sprintf(`# cc-provider shell integration for nushell
+# This wraps the cc-provider command to enable immediate activation
+
+def --env --wrapped cc-provider [...args] {
+    # Use the config directory this integration was generated for unless overridden
+    let home = ($env.CC_PROVIDER_HOME? | default %s)
+
+    # Commands write the updates for the current shell to a directives file,
+    # one JSON record per line, which is applied once they exit
+    let directives = (mktemp --tmpdir cc-provider.XXXXXX)
+    # A failure is caught so the directives file is always removed, and raised
+    # again below. The output is not captured, so prompts keep working.
+    let failure = try {
+        with-env {CC_PROVIDER_HOME: $home, CC_PROVIDER_DIRECTIVES: $directives, CC_PROVIDER_DIRECTIVES_SHELL: 'nu'} { ^cc-provider ...$args }
+        null
+    } catch {|err| $err }
+    let activations = (open --raw $directives | lines | where {|line| $line != ''} | each {|line| $line | from json})
+    rm -f $directives
+    for activation in $activations {
+        hide-env --ignore-errors ...$activation.unset
+        load-env $activation.set
+        print --stderr $activation.message
+    }
+    if $failure != null {
+        error make --unspanned {msg: $failure.msg}
+    }
+}
+`, nuQuote(cfgDir))
+}
+
+// ensureNuConfig installs the nushell integration and sources it from config.nu.
+// nushell has no completion generator, so no completion is installed.
+// 安装 nushell 集成并在 config.nu 中加载
+func ensureNuConfig(forceUpdate bool) (string, error) {
+	configPath, err := nuConfigPath()
+	if err != nil {
+		return "", err
+	}
+
+	// nushell resolves source at parse time, so the script must always exist
+	activeScript := activeEnvScriptPath(nuDialect)
+	if _, err := os.Stat(activeScript); os.IsNotExist(err) {
+		if err := writeGeneratedScript(activeScript, []byte("# cc-provider active environment script\n"), 0600); err != nil {
+			return "", fmt.Errorf("creating empty %s: %w", filepath.Base(activeScript), err)
+		}
+	}
+
+	shellFunctionPath := filepath.Join(shellDir, "shell_function.nu")
+	if err := writeGeneratedScript(shellFunctionPath, []byte(nuShellFunction()), 0644); err != nil {
+		return "", fmt.Errorf("creating shell function file: %w", err)
+	}
+
+	lines := []string{
+		"source " + nuQuote(activeScript),
+		"source " + nuQuote(shellFunctionPath),
+	}
+	if err := ensureManagedBlock(configPath, lines); err != nil {
+		return "", err
+	}
+	return configPath, nil
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats accepted by the --output flag of list, inspect and template list/show. table is the text meant for
+// people; json and yaml print the stable schemas below for scripts and editor
+// plugins, which never contain an unmasked secret.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFormats = []string{outputTable, outputJSON, outputYAML}
+
+var outputFormat string // 输出格式 / Output format
+
+// environmentInfo describes an environment in list and inspect output.
+// 环境信息的机器可读格式
+type environmentInfo struct {
+	Name string `json:"name" yaml:"name"`
+	// Active is set for the environment of the current shell.
+	Active bool `json:"active" yaml:"active"`
+	// Default is set for the environment new shells start with.
+	Default       bool       `json:"default" yaml:"default"`
+	Description   string     `json:"description" yaml:"description"`
+	Template      string     `json:"template" yaml:"template"`
+	Tags          []string   `json:"tags" yaml:"tags"`
+	Extends       string     `json:"extends" yaml:"extends"`
+	CreatedAt     time.Time  `json:"created_at" yaml:"created_at"`
+	ModifiedAt    time.Time  `json:"modified_at" yaml:"modified_at"`
+	LastActivated *time.Time `json:"last_activated" yaml:"last_activated"`
+}
+
+// environmentList is the list output.
+type environmentList struct {
+	Environments []environmentInfo `json:"environments" yaml:"environments"`
+}
+
+// environmentDetails is the inspect output: the environment with the chain of
+// environments it extends and its effective variables.
+type environmentDetails struct {
+	environmentInfo `yaml:",inline"`
+	ExtendsChain    []string       `json:"extends_chain" yaml:"extends_chain"`
+	Variables       []variableInfo `json:"variables" yaml:"variables"`
+}
+
+// variableInfo is a variable as shown in machine-readable output.
+type variableInfo struct {
+	Key string `json:"key" yaml:"key"`
+	// Value is masked for secrets; secret references are left unresolved.
+	Value  string `json:"value" yaml:"value"`
+	Masked bool   `json:"masked" yaml:"masked"`
+	// Source is the environment the value comes from, for inspect.
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+}
+
+// templateList is the template list output.
+type templateList struct {
+	Templates []templateInfo `json:"templates" yaml:"templates"`
+}
+
+// templateInfo describes a template in template list and show output.
+type templateInfo struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+	// Source is "built-in" or "custom".
+	Source    string         `json:"source" yaml:"source"`
+	Variables []variableInfo `json:"variables" yaml:"variables"`
+}
+
+// mustValidateOutputFormat exits with an error if --output is not supported.
+// Structured output is read by scripts, so nothing may prompt for input then.
+func mustValidateOutputFormat() {
+	if !slices.Contains(outputFormats, outputFormat) {
+		fmt.Fprintf(os.Stderr, "Error: Unsupported output format '%s' (supported: %s).\n", outputFormat, strings.Join(outputFormats, ", "))
+		os.Exit(1)
+	}
+	if structuredOutput() {
+		promptsDisabled = true
+	}
+}
+
+// structuredOutput reports whether --output asks for JSON or YAML.
+func structuredOutput() bool {
+	return outputFormat != outputTable
+}
+
+// printStructured prints v as JSON or YAML, as --output asks.
+// 按 --output 以 JSON 或 YAML 格式打印
+func printStructured(v any) {
+	var data []byte
+	var err error
+	if outputFormat == outputYAML {
+		data, err = yaml.Marshal(v)
+	} else {
+		data, err = json.MarshalIndent(v, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(data)
+}
+
+// newEnvironmentInfo collects what list and inspect report about envName.
+func newEnvironmentInfo(reg *Registry, envName string) environmentInfo {
+	info := environmentInfo{
+		Name:    envName,
+		Active:  envName == os.Getenv("CC_PROVIDER_ACTIVE_ENV"),
+		Default: envName == reg.Active,
+		Tags:    []string{},
+	}
+	if meta := reg.Get(envName); meta != nil {
+		info.Description = meta.Description
+		info.Template = meta.Template
+		info.Tags = append(info.Tags, meta.Tags...)
+		info.CreatedAt = meta.CreatedAt
+		info.ModifiedAt = meta.ModifiedAt
+		info.LastActivated = meta.LastActivated
+	}
+	if doc, err := readEnvDocument(environmentPath(envName)); err == nil {
+		info.Extends = baseEnvName(doc)
+	}
+	return info
+}
+
+// newVariableInfos returns vars in canonical key order, secrets masked.
+func newVariableInfos(vars map[string]string) []variableInfo {
+	infos := []variableInfo{}
+	for _, key := range sortedEnvKeys(vars) {
+		value := vars[key]
+		infos = append(infos, variableInfo{
+			Key:    key,
+			Value:  displayValue(key, value),
+			Masked: isSecretKey(key) && !isSecretRef(value),
+		})
+	}
+	return infos
+}
+
+// newTemplateInfo describes tmpl for template list and show.
+func newTemplateInfo(tmpl *Template) templateInfo {
+	source := "custom"
+	if _, ok := builtInTemplates[tmpl.Name]; ok {
+		source = "built-in"
+	}
+	return templateInfo{
+		Name:        tmpl.Name,
+		Description: tmpl.Description,
+		Source:      source,
+		Variables:   newVariableInfos(tmpl.EnvVars),
+	}
+}
+
+func init() {
+	// Only the commands that print structured output accept --output
+	for _, c := range []*cobra.Command{listCmd, inspectCmd, templateListCmd, templateShowCmd} {
+		c.Flags().StringVarP(&outputFormat, "output", "o", outputTable, "Output format ("+strings.Join(outputFormats, ", ")+")")
+		c.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(outputFormats, cobra.ShellCompDirectiveNoFileComp))
+	}
+}
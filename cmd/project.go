@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A project file, .cc-provider, names the environment used in a directory tree:
+//
+//	# Client work uses the client's key
+//	CC_PROVIDER_ENV=client
+//	ANTHROPIC_MODEL=claude-sonnet-4-5
+//
+// A line with just a name is accepted as well. The other variables override
+// the values of the environment. Project files come with the repositories
+// they live in and are applied on cd, so they may only choose among the
+// user's own environments and tune the model settings: any other variable
+// could run code or redirect traffic, and values are never resolved as
+// secret references.
+// 项目文件 .cc-provider 指定目录树中使用的环境,并可覆盖部分变量。
+const projectFileName = ".cc-provider"
+
+const (
+	// projectEnvKey names the environment in a project file.
+	projectEnvKey = "CC_PROVIDER_ENV"
+	// projectPathEnv holds the project file applied by the hook in the current shell.
+	projectPathEnv = "CC_PROVIDER_PROJECT"
+	// projectPreviousEnv holds the environment to restore when leaving the project.
+	projectPreviousEnv = "CC_PROVIDER_PROJECT_PREVIOUS"
+)
+
+// projectStateKeys are the variables the hook keeps in the shell. They are
+// unset by every activation, so a new shell or an explicit activate starts over.
+var projectStateKeys = []string{projectPathEnv, projectPreviousEnv}
+
+// projectOverridableKeys are the only variables a project file may override.
+var projectOverridableKeys = []string{
+	"ANTHROPIC_MODEL",
+	"ANTHROPIC_SMALL_FAST_MODEL",
+	"ANTHROPIC_DEFAULT_HAIKU_MODEL",
+	"ANTHROPIC_DEFAULT_SONNET_MODEL",
+	"ANTHROPIC_DEFAULT_OPUS_MODEL",
+	"CLAUDE_CODE_SUBAGENT_MODEL",
+	"CLAUDE_CODE_EFFORT_LEVEL",
+	"API_TIMEOUT_MS",
+}
+
+// projectFile is a parsed .cc-provider file.
+type projectFile struct {
+	path      string
+	envName   string
+	overrides []EnvVar
+}
+
+// findProjectFile returns the nearest project file in dir or its parents.
+func findProjectFile(dir string) (string, bool) {
+	for {
+		path := filepath.Join(dir, projectFileName)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// loadProjectFile reads and validates the project file at path.
+func loadProjectFile(path string) (*projectFile, error) {
+	doc, err := readEnvDocument(path)
+	if err != nil {
+		return nil, err
+	}
+
+	proj := &projectFile{path: path}
+	for _, line := range doc.lines {
+		raw := strings.TrimSpace(line.raw)
+		if line.isVar || raw == "" || strings.HasPrefix(raw, "#") {
+			continue
+		}
+		if proj.envName != "" {
+			return nil, fmt.Errorf("unexpected line %q", raw)
+		}
+		proj.envName = raw
+	}
+
+	for _, v := range doc.Vars() {
+		switch {
+		case v.Key == projectEnvKey:
+			if proj.envName != "" && proj.envName != v.Value {
+				return nil, fmt.Errorf("environment named twice: '%s' and '%s'", proj.envName, v.Value)
+			}
+			proj.envName = v.Value
+		case !isValidEnvKey(v.Key):
+			return nil, fmt.Errorf("invalid environment variable name %q", v.Key)
+		case !slices.Contains(projectOverridableKeys, v.Key):
+			return nil, fmt.Errorf("%s cannot be set in a project file (allowed: %s)", v.Key, strings.Join(projectOverridableKeys, ", "))
+		case isSecretRef(v.Value):
+			return nil, fmt.Errorf("%s: secret references are not allowed in a project file", v.Key)
+		default:
+			proj.overrides = append(proj.overrides, v)
+		}
+	}
+
+	if proj.envName == "" {
+		return nil, fmt.Errorf("no environment named (add %s=<env-name>)", projectEnvKey)
+	}
+	if err := validateEnvName(proj.envName); err != nil {
+		return nil, err
+	}
+	return proj, nil
+}
+
+// projectActivationScript builds the activation of a project: its environment
+// with the overrides applied, remembering previous so it can be restored.
+func projectActivationScript(proj *projectFile, previous string) (*activationScript, error) {
+	if !mustLoadRegistry().Has(proj.envName) {
+		return nil, fmt.Errorf("environment '%s' not found", proj.envName)
+	}
+	script, err := loadHookActivationScript(proj.envName)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range proj.overrides {
+		script.setVar(v.Key, v.Value)
+	}
+	script.setVar(projectPathEnv, proj.path)
+	if previous != "" {
+		script.setVar(projectPreviousEnv, previous)
+	}
+	script.message = fmt.Sprintf("cc-provider: using environment '%s' for %s", proj.envName, filepath.Dir(proj.path))
+	return script, nil
+}
+
+// projectRestoreScript builds the script that leaves a project, restoring the
+// environment that was active before it, or deactivating if there was none.
+func projectRestoreScript(previous string) (*activationScript, error) {
+	if previous == "" || !mustLoadRegistry().Has(previous) {
+		script := newDeactivationScript("")
+		script.message = "cc-provider: left project, no environment active"
+		return script, nil
+	}
+	script, err := loadHookActivationScript(previous)
+	if err != nil {
+		return nil, err
+	}
+	script.message = fmt.Sprintf("cc-provider: left project, restored environment '%s'", previous)
+	return script, nil
+}
+
+// loadHookActivationScript builds the activation of envName for the hook.
+// When the shell already uses envName, its secrets keep the values the shell
+// holds, so moving between projects of one environment never resolves them
+// again; they are only resolved when the environment changes.
+// 钩子只在目标环境变化时才重新解析密钥引用
+func loadHookActivationScript(envName string) (*activationScript, error) {
+	resolved, err := resolveEnvironment(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	sameEnv := os.Getenv("CC_PROVIDER_ACTIVE_ENV") == envName
+	var r secretResolver
+	vars := make([]EnvVar, 0, len(resolved.vars))
+	for _, ev := range resolved.vars {
+		if current, ok := os.LookupEnv(ev.Key); ok && sameEnv && isSecretRef(ev.Value) {
+			vars = append(vars, EnvVar{Key: ev.Key, Value: current})
+			continue
+		}
+		value, err := r.resolve(ev.Value)
+		if err != nil {
+			return nil, fmt.Errorf("environment '%s': resolving %s (%s): %w", envName, ev.Key, ev.Value, err)
+		}
+		vars = append(vars, EnvVar{Key: ev.Key, Value: value})
+	}
+
+	script, err := newActivationScript(envName, vars)
+	if err != nil {
+		return nil, fmt.Errorf("environment '%s': %w", envName, err)
+	}
+	return script, nil
+}
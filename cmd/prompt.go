@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	promptFormat      string // 输出格式 / Format of the segment
+	promptIcon        string // 图标 / Icon shown by {icon}
+	promptDriftSymbol string // 漂移标记 / Shown by {drift}
+	promptInit        string // 输出配置片段的目标 / Prompt to print the snippet for
+)
+
+// defaultPromptFormat is the segment printed without --format.
+const defaultPromptFormat = "{icon}{env}{drift} {model}"
+
+// promptCmd prints a short segment describing the active environment for
+// the shell prompt. It runs before every prompt, so it only reads the active
+// environment name and its files: secret references are never resolved and
+// nothing is fetched over the network.
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Prints a prompt segment showing the active environment.",
+	Long: `Prints a short segment showing the environment active in the current shell, for use in a shell prompt.
+Nothing is printed when no environment is active.
+
+The format may use these placeholders:
+  {env}    name of the active environment
+  {model}  ANTHROPIC_MODEL of the environment
+  {icon}   the --icon, followed by a space
+  {drift}  the --drift-symbol when the shell is out of step: it is not on the default
+           environment (or the one of its project), or the environment has changed since
+           it was activated
+
+Use --init bash|zsh|fish|pwsh|nu|starship to print a snippet that adds the segment to your prompt.`,
+	// Run before every prompt, so it must be fast and never wait for the lock
+	Annotations: map[string]string{readOnlyAnnotation: "true"},
+	Args:        cobra.NoArgs,
+	Run:         runPromptCmd,
+}
+
+func runPromptCmd(cmd *cobra.Command, args []string) {
+	if promptInit != "" {
+		snippet, ok := promptSnippets[promptInit]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: Unsupported prompt '%s' (supported: %s).\n", promptInit, strings.Join(promptSnippetNames(), ", "))
+			os.Exit(1)
+		}
+		fmt.Print(snippet)
+		return
+	}
+
+	envName := os.Getenv("CC_PROVIDER_ACTIVE_ENV")
+	if envName == "" {
+		return
+	}
+
+	// A missing or broken environment is shown as drifted rather than failing the prompt
+	expected, err := expectedShellValues(envName)
+	drift := err != nil || promptDrift(envName, expected)
+
+	model := expected["ANTHROPIC_MODEL"]
+	if isSecretRef(model) {
+		model = ""
+	}
+	icon := promptIcon
+	if icon != "" {
+		icon += " "
+	}
+	driftSymbol := ""
+	if drift {
+		driftSymbol = promptDriftSymbol
+	}
+
+	segment := strings.NewReplacer(
+		"{env}", envName,
+		"{model}", model,
+		"{icon}", icon,
+		"{drift}", driftSymbol,
+	).Replace(promptFormat)
+	fmt.Print(strings.TrimSpace(segment))
+}
+
+// expectedShellValues returns the variables an activation of envName sets in
+// the current shell, including the overrides of the project it is in.
+func expectedShellValues(envName string) (map[string]string, error) {
+	resolved, err := resolveEnvironment(envName)
+	if err != nil {
+		return nil, err
+	}
+	values := resolved.Map()
+	for _, key := range stateKeys() {
+		delete(values, key)
+	}
+
+	if path := os.Getenv(projectPathEnv); path != "" {
+		proj, err := loadProjectFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if proj.envName != envName {
+			return nil, fmt.Errorf("project file %s now names '%s'", path, proj.envName)
+		}
+		for _, v := range proj.overrides {
+			values[v.Key] = v.Value
+		}
+	}
+	return values, nil
+}
+
+// promptDrift reports whether the shell and the persisted state disagree.
+func promptDrift(envName string, expected map[string]string) bool {
+	// New shells start with the default environment, unless this shell
+	// follows a project or was started by 'cc-provider shell'
+	if os.Getenv(projectPathEnv) == "" && os.Getenv(subshellEnv) == "" {
+		reg, err := loadRegistry()
+		if err != nil || reg.Active != envName {
+			return true
+		}
+	}
+
+	// The values in the shell must still be those of the environment files.
+	// Secret references cannot be compared without resolving them.
+	for key, value := range expected {
+		if isSecretRef(value) {
+			continue
+		}
+		if current, ok := os.LookupEnv(key); !ok || current != value {
+			return true
+		}
+	}
+	if recorded, ok := os.LookupEnv(managedKeysEnv); ok {
+		for _, key := range strings.Fields(recorded) {
+			if _, ok := expected[key]; !ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// promptSnippets add the segment to each supported prompt. They run the
+// binary rather than the shell function, which would set up a directives file
+// on every prompt.
+var promptSnippets = map[string]string{
+	"bash": `# cc-provider prompt segment, add to ~/.bashrc after the shell integration
+_cc_provider_prompt() {
+    local segment
+    segment=$(command cc-provider prompt 2>/dev/null)
+    [ -n "$segment" ] && printf '(%s) ' "$segment"
+}
+PS1='$(_cc_provider_prompt)'"$PS1"
+`,
+	"zsh": `# cc-provider prompt segment, add to ~/.zshrc after the shell integration
+setopt PROMPT_SUBST
+RPROMPT='$(command cc-provider prompt 2>/dev/null)'"$RPROMPT"
+`,
+	"fish": `# cc-provider prompt segment, add to ~/.config/fish/config.fish
+function fish_right_prompt
+    command cc-provider prompt 2>/dev/null
+end
+`,
+	"pwsh": `# cc-provider prompt segment, add to $PROFILE after the shell integration
+$__ccProviderPrompt = $function:prompt
+function prompt {
+    $segment = & (Get-Command -CommandType Application cc-provider | Select-Object -First 1) prompt 2>$null
+    if ($segment) { "($segment) " + (& $__ccProviderPrompt) } else { & $__ccProviderPrompt }
+}
+`,
+	"nu": `# cc-provider prompt segment, add to config.nu after the shell integration
+$env.PROMPT_COMMAND_RIGHT = {|| ^cc-provider prompt | complete | get stdout | str trim }
+`,
+	"starship": `# cc-provider prompt segment, add to ~/.config/starship.toml
+[custom.cc_provider]
+description = "Active cc-provider environment"
+command = "cc-provider prompt"
+when = 'test -n "$CC_PROVIDER_ACTIVE_ENV"'
+shell = ["sh"]
+format = "[$output]($style) "
+style = "bold purple"
+`,
+}
+
+// promptSnippetNames returns the prompts --init supports, sorted.
+func promptSnippetNames() []string {
+	var names []string
+	for name := range promptSnippets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func init() {
+	rootCmd.AddCommand(promptCmd)
+	promptCmd.Flags().StringVarP(&promptFormat, "format", "f", defaultPromptFormat, "Format of the segment, with {env}, {model}, {icon} and {drift} placeholders")
+	promptCmd.Flags().StringVar(&promptIcon, "icon", "", "Icon shown by {icon}")
+	promptCmd.Flags().StringVar(&promptDriftSymbol, "drift-symbol", "!", "Symbol shown by {drift} when the shell is out of step")
+	promptCmd.Flags().StringVar(&promptInit, "init", "", "Print a snippet that adds the segment to a prompt ("+strings.Join(promptSnippetNames(), ", ")+")")
+	promptCmd.RegisterFlagCompletionFunc("init", cobra.FixedCompletions(promptSnippetNames(), cobra.ShellCompDirectiveNoFileComp))
+}
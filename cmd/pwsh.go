@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// pwshQuote quotes s for PowerShell. Single-quoted strings expand nothing; a
+// quote is escaped by doubling it. PowerShell also accepts the typographic
+// single quotes as quote characters, so those are doubled as well.
+// 为 PowerShell 安全地引用字符串
+func pwshQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '‘', '’', '‚', '‛':
+			sb.WriteRune(r)
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteByte('\'')
+	return sb.String()
+}
+
+// pwshDialect activates with $env:KEY assignments and Remove-Item Env:KEY.
+var pwshDialect = commandDialect{
+	ext:   ".ps1",
+	unset: func(key string) string { return "Remove-Item -ErrorAction SilentlyContinue Env:" + key },
+	set:   func(key, value string) string { return fmt.Sprintf("$env:%s = %s", key, pwshQuote(value)) },
+	setResolved: func(key string, args []string) string {
+		// The binary, not the shell function, as in the function itself
+		return fmt.Sprintf("$env:%s = (& (Get-Command -CommandType Application cc-provider | Select-Object -First 1) %s) -join \"`n\"", key, quoteEach(args, pwshQuote))
+	},
+	echoErr: func(msg string) string { return fmt.Sprintf("[Console]::Error.WriteLine(%s)", pwshQuote(msg)) },
+	unsetRecorded: func(fallback []string) string {
+		paths := make([]string, len(fallback))
+		for i, key := range fallback {
+			paths[i] = "Env:" + key
+		}
+		return fmt.Sprintf(`if (Test-Path Env:%[1]s) {
+    foreach ($__ccProviderKey in $env:%[1]s.Split(' ', [StringSplitOptions]::RemoveEmptyEntries)) { Remove-Item -ErrorAction SilentlyContinue "Env:$__ccProviderKey" }
+    Remove-Variable -ErrorAction SilentlyContinue __ccProviderKey
+} else {
+    Remove-Item -ErrorAction SilentlyContinue %[2]s
+}`, managedKeysEnv, strings.Join(paths, ", "))
+	},
+	guard: "if ($env:" + subshellEnv + ") { return }",
+}
+
+// pwshProfilePath returns the profile PowerShell loads for the current user.
+func pwshProfilePath(home string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1")
+	}
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(base) {
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "powershell", "Microsoft.PowerShell_profile.ps1")
+}
+
+// pwshShellFunction returns the PowerShell wrapper that enables immediate activation.
+func pwshShellFunction() string {
+	return fmt.Sprintf(`# cc-provider shell integration for PowerShell
+# This wraps the cc-provider command to enable immediate activation
+
+function cc-provider {
+    $binary = Get-Command -CommandType Application cc-provider | Select-Object -First 1
+
+    # Use the config directory this integration was generated for unless overridden
+    $previousHome = $env:CC_PROVIDER_HOME
+    if (-not $env:CC_PROVIDER_HOME) { $env:CC_PROVIDER_HOME = %s }
+
+    # Commands write the updates for the current shell to a directives file,
+    # which is applied once they exit; arguments are passed on unchanged
+    $directives = New-TemporaryFile
+    $env:CC_PROVIDER_DIRECTIVES = $directives.FullName
+    $env:CC_PROVIDER_DIRECTIVES_SHELL = 'pwsh'
+    try {
+        & $binary @args
+    } finally {
+        $env:CC_PROVIDER_HOME = $previousHome
+        Remove-Item -ErrorAction SilentlyContinue Env:CC_PROVIDER_DIRECTIVES, Env:CC_PROVIDER_DIRECTIVES_SHELL
+        $updates = Get-Content -Raw $directives.FullName
+        Remove-Item -ErrorAction SilentlyContinue $directives.FullName
+        if ($updates) { Invoke-Expression $updates }
+    }
+}
+`, pwshQuote(cfgDir))
+}
+
+// ensurePwshConfig installs the PowerShell integration and dot-sources it from
+// the user's profile.
+// 安装 PowerShell 集成并在配置文件中加载
+func ensurePwshConfig(forceUpdate bool) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding home directory: %w", err)
+	}
+	profilePath := pwshProfilePath(home)
+
+	activeScript := activeEnvScriptPath(pwshDialect)
+	if _, err := os.Stat(activeScript); os.IsNotExist(err) {
+		if err := writeGeneratedScript(activeScript, []byte("# cc-provider active environment script\n"), 0600); err != nil {
+			return "", fmt.Errorf("creating empty %s: %w", filepath.Base(activeScript), err)
+		}
+	}
+
+	shellFunctionPath := filepath.Join(shellDir, "shell_function.ps1")
+	if err := writeGeneratedScript(shellFunctionPath, []byte(pwshShellFunction()), 0644); err != nil {
+		return "", fmt.Errorf("creating shell function file: %w", err)
+	}
+
+	completionFilePath := filepath.Join(shellDir, "completion.ps1")
+	if _, err := os.Stat(completionFilePath); os.IsNotExist(err) || forceUpdate {
+		var completion bytes.Buffer
+		if err := rootCmd.GenPowerShellCompletionWithDesc(&completion); err != nil {
+			return "", fmt.Errorf("generating completion: %w", err)
+		}
+		if err := writeGeneratedScript(completionFilePath, completion.Bytes(), 0644); err != nil {
+			return "", fmt.Errorf("creating completion file: %w", err)
+		}
+	}
+
+	lines := []string{
+		". " + pwshQuote(activeScript),
+		". " + pwshQuote(completionFilePath),
+		". " + pwshQuote(shellFunctionPath),
+	}
+	if err := ensureManagedBlock(profilePath, lines); err != nil {
+		return "", err
+	}
+	return profilePath, nil
+}
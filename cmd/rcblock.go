@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The lines cc-provider adds to shell configuration files are kept in a
+// managed block, which is updated in place and removed by uninstall:
+//
+//	# >>> cc-provider >>>
+//	# Managed by cc-provider. Changes inside this block will be overwritten.
+//	source '/home/me/.cc-provider/shell/active_env.sh'
+//	# <<< cc-provider <<<
+//
+// 写入 shell 配置文件的内容保存在带标记的托管块中
+const (
+	managedBlockBegin = "# >>> cc-provider >>>"
+	managedBlockEnd   = "# <<< cc-provider <<<"
+	managedBlockNote  = "# Managed by cc-provider. Changes inside this block will be overwritten."
+
+	// legacyBlockHeader preceded the lines older versions appended without markers.
+	legacyBlockHeader = "# Added by cc-provider for environment activation and auto-completion"
+)
+
+// shellConfigDryRun makes shell configuration changes print a diff instead of
+// being written.
+var shellConfigDryRun bool
+
+// generatedScriptNames are the prefixes of the scripts sourced from shell
+// configuration files.
+var generatedScriptNames = []string{"active_env.", "completion.", "shell_function."}
+
+// setManagedBlock returns content with the managed block holding lines. An
+// existing block, or the lines older versions added, are replaced in place;
+// otherwise the block is appended.
+func setManagedBlock(content string, lines []string) (string, error) {
+	out, at, err := stripManagedConfig(content)
+	if err != nil {
+		return "", err
+	}
+
+	block := append([]string{managedBlockBegin, managedBlockNote}, lines...)
+	block = append(block, managedBlockEnd)
+	if at < 0 {
+		at = len(out)
+	}
+	if at > 0 && strings.TrimSpace(out[at-1]) != "" {
+		block = append([]string{""}, block...)
+	}
+	out = append(out[:at], append(block, out[at:]...)...)
+	return joinLines(out), nil
+}
+
+// removeManagedBlock returns content without the managed block and the lines
+// older versions added.
+func removeManagedBlock(content string) (string, error) {
+	out, at, err := stripManagedConfig(content)
+	if err != nil {
+		return "", err
+	}
+	// Drop the blank line that separated an appended block
+	if at > 0 && at == len(out) && strings.TrimSpace(out[at-1]) == "" {
+		out = out[:at-1]
+	}
+	return joinLines(out), nil
+}
+
+// stripManagedConfig splits content into lines, leaving out the managed
+// block and the lines older versions added. It returns the position where
+// they were, or -1.
+func stripManagedConfig(content string) ([]string, int, error) {
+	in := splitLines(strings.ReplaceAll(content, "\r\n", "\n"))
+	var out []string
+	at := -1
+	for i := 0; i < len(in); i++ {
+		line := strings.TrimSpace(in[i])
+		switch {
+		case line == managedBlockBegin:
+			end := i + 1
+			for end < len(in) && strings.TrimSpace(in[end]) != managedBlockEnd {
+				end++
+			}
+			if end == len(in) {
+				return nil, 0, fmt.Errorf("line %d: '%s' without '%s'", i+1, managedBlockBegin, managedBlockEnd)
+			}
+			i = end
+		case line == legacyBlockHeader:
+			if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) == "" {
+				out = out[:len(out)-1]
+			}
+		case isLegacySourceLine(line):
+		default:
+			out = append(out, in[i])
+			continue
+		}
+		if at < 0 {
+			at = len(out)
+		}
+	}
+	return out, at, nil
+}
+
+// isLegacySourceLine reports whether line sources one of the generated
+// scripts, as the lines older versions added without markers did.
+func isLegacySourceLine(line string) bool {
+	command, path, ok := strings.Cut(line, " ")
+	if !ok || (command != "source" && command != ".") {
+		return false
+	}
+	path = strings.TrimRight(strings.TrimLeft(strings.TrimSpace(path), `r#'"`), `'#"`)
+	if !strings.Contains(path, "cc-provider") && !strings.HasPrefix(path, cfgDir) {
+		return false
+	}
+	for _, name := range generatedScriptNames {
+		if strings.HasPrefix(filepath.Base(path), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinLines joins lines into file content ending with a newline.
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// ensureManagedBlock makes the managed block of the shell configuration file
+// at rcPath hold lines, creating the file if needed.
+func ensureManagedBlock(rcPath string, lines []string) error {
+	content, err := os.ReadFile(rcPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading %s: %w", rcPath, err)
+	}
+	updated, err := setManagedBlock(string(content), lines)
+	if err != nil {
+		return fmt.Errorf("%s: %w", rcPath, err)
+	}
+
+	changed, err := writeShellConfigFile(rcPath, []byte(updated))
+	if err != nil || !changed || shellConfigDryRun {
+		return err
+	}
+	hadConfig := strings.Contains(string(content), managedBlockBegin) || strings.Contains(string(content), legacyBlockHeader)
+	if !hadConfig {
+		fmt.Printf("Added configuration to '%s' for automatic environment loading and tab completion.\n", rcPath)
+	} else {
+		fmt.Printf("Updated shell configuration in '%s'.\n", rcPath)
+	}
+	fmt.Println("Please restart your shell or source your config file to apply changes.")
+	return nil
+}
+
+// writeShellConfigFile writes a shell configuration file owned by the user,
+// keeping its permissions. It reports whether the content changed. With
+// shellConfigDryRun, the diff is printed instead.
+func writeShellConfigFile(path string, content []byte) (bool, error) {
+	current, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if bytes.Equal(current, content) {
+		return false, nil
+	}
+	if shellConfigDryRun {
+		fmt.Print(unifiedDiff(path, string(current), string(content)))
+		return true, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("creating directory '%s': %w", filepath.Dir(path), err)
+	}
+	if err := writeFileAtomic(path, content, fileModeOr(path, 0644)); err != nil {
+		return false, fmt.Errorf("writing to %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// writeGeneratedScript writes a script of the shell directory if its content
+// changed. Nothing is written with shellConfigDryRun.
+func writeGeneratedScript(path string, data []byte, perm os.FileMode) error {
+	if shellConfigDryRun {
+		return nil
+	}
+	_, err := writeFileIfChanged(path, data, perm)
+	return err
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const registryVersion = 1
+
+// EnvMeta holds the metadata recorded for an environment.
+// EnvMeta 保存环境的元数据
+type EnvMeta struct {
+	Description   string     `json:"description,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ModifiedAt    time.Time  `json:"modifiedAt"`
+	Template      string     `json:"template,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	LastActivated *time.Time `json:"lastActivated,omitempty"`
+}
+
+// Registry is the manifest of all environments. It is the single source of
+// truth for what counts as an environment, so stray files in the config
+// directory are never mistaken for one.
+// Registry 是所有环境的清单,决定哪些文件算作环境。
+type Registry struct {
+	Version int `json:"version"`
+	// Active is the default environment, which new shells start with.
+	Active       string              `json:"active,omitempty"`
+	Environments map[string]*EnvMeta `json:"environments"`
+}
+
+// registryPath returns the path of the registry file.
+func registryPath() string {
+	return filepath.Join(stateDir, "registry.json")
+}
+
+// environmentPath returns the path of the file holding an environment.
+func environmentPath(envName string) string {
+	return filepath.Join(envsDir, envName)
+}
+
+// loadRegistry reads the registry. If none exists yet, it is rebuilt from
+// the environment files on disk.
+func loadRegistry() (*Registry, error) {
+	data, err := os.ReadFile(registryPath())
+	if os.IsNotExist(err) {
+		return rebuildRegistry()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading registry: %w", err)
+	}
+
+	var reg Registry
+	if err := json.Unmarshal(data, &reg); err != nil {
+		return nil, fmt.Errorf("parsing registry: %w", err)
+	}
+	if reg.Environments == nil {
+		reg.Environments = make(map[string]*EnvMeta)
+	}
+	return &reg, nil
+}
+
+// mustLoadRegistry loads the registry or exits with an error.
+func mustLoadRegistry() *Registry {
+	reg, err := loadRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading environment registry: %v\n", err)
+		os.Exit(1)
+	}
+	return reg
+}
+
+// save writes the registry to disk.
+func (r *Registry) save() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding registry: %w", err)
+	}
+	if err := writeFileAtomic(registryPath(), data, 0644); err != nil {
+		return fmt.Errorf("writing registry: %w", err)
+	}
+	return nil
+}
+
+// Names returns the sorted names of registered environments whose file exists.
+// Environments whose name is not valid (see validateEnvName) are left out.
+func (r *Registry) Names() []string {
+	var names []string
+	for name := range r.Environments {
+		if r.Has(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has reports whether name is a valid, registered environment with a file on disk.
+func (r *Registry) Has(name string) bool {
+	if validateEnvName(name) != nil {
+		return false
+	}
+	if _, ok := r.Environments[name]; !ok {
+		return false
+	}
+	info, err := os.Stat(environmentPath(name))
+	return err == nil && !info.IsDir()
+}
+
+// Get returns the metadata of name, or nil if it is not registered.
+func (r *Registry) Get(name string) *EnvMeta {
+	return r.Environments[name]
+}
+
+// Add registers a new environment.
+func (r *Registry) Add(name string, meta *EnvMeta) {
+	now := time.Now().UTC().Truncate(time.Second)
+	if meta.CreatedAt.IsZero() {
+		meta.CreatedAt = now
+	}
+	if meta.ModifiedAt.IsZero() {
+		meta.ModifiedAt = now
+	}
+	r.Environments[name] = meta
+}
+
+// Touch records a modification of name.
+func (r *Registry) Touch(name string) {
+	r.ensure(name).ModifiedAt = time.Now().UTC().Truncate(time.Second)
+}
+
+// MarkActivated records an activation of name.
+func (r *Registry) MarkActivated(name string) {
+	now := time.Now().UTC().Truncate(time.Second)
+	r.ensure(name).LastActivated = &now
+}
+
+// SetActive makes name the default environment.
+func (r *Registry) SetActive(name string) {
+	r.Active = name
+}
+
+// ClearActive records that there is no default environment.
+func (r *Registry) ClearActive() {
+	r.Active = ""
+}
+
+// Remove unregisters name. If it was the active environment, none is active afterwards.
+func (r *Registry) Remove(name string) {
+	delete(r.Environments, name)
+	if r.Active == name {
+		r.ClearActive()
+	}
+}
+
+// Rename moves the metadata of oldName to newName. If oldName was the active
+// environment, newName is active afterwards.
+func (r *Registry) Rename(oldName, newName string) {
+	r.Environments[newName] = r.ensure(oldName)
+	delete(r.Environments, oldName)
+	if r.Active == oldName {
+		r.SetActive(newName)
+	}
+}
+
+// ensure returns the metadata of name, creating an entry if needed.
+func (r *Registry) ensure(name string) *EnvMeta {
+	meta, ok := r.Environments[name]
+	if !ok {
+		meta = &EnvMeta{}
+		r.Add(name, meta)
+	}
+	return meta
+}
+
+// updateRegistry loads the registry, applies fn and saves it.
+func updateRegistry(fn func(r *Registry)) error {
+	reg, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	fn(reg)
+	return reg.save()
+}
+
+// rebuildRegistry builds a registry from the files in the envs directory.
+// It is used when the registry is missing, so no environment is lost.
+// 当注册表缺失时,根据 envs 目录中的文件重建注册表
+func rebuildRegistry() (*Registry, error) {
+	reg := &Registry{Version: registryVersion, Environments: make(map[string]*EnvMeta)}
+
+	files, err := os.ReadDir(envsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading environments directory: %w", err)
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !looksLikeEnvFile(environmentPath(name)) {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		modified := info.ModTime().UTC().Truncate(time.Second)
+		reg.Add(name, &EnvMeta{CreatedAt: modified, ModifiedAt: modified})
+	}
+
+	// Read-only commands run without the lock, so they leave saving to others
+	if readOnlyRun {
+		return reg, nil
+	}
+	if err := reg.save(); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
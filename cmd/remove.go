@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -21,61 +20,62 @@ var removeCmd = &cobra.Command{
 
 func runRemoveCmd(cmd *cobra.Command, args []string) {
 	envName := args[0]
-	envFilePath := filepath.Join(cfgDir, envName)
+	mustValidateEnvName(envName)
+	envFilePath := environmentPath(envName)
+
+	unlock := mustLockConfig()
+	defer unlock()
+	reg := mustLoadRegistry()
 
 	// 1. Validate environment exists
-	if _, err := os.Stat(envFilePath); os.IsNotExist(err) {
+	if !reg.Has(envName) {
 		fmt.Fprintf(os.Stderr, "Error: Environment '%s' not found. // 错误: 未找到环境 '%s'。\n", envName, envName)
 		os.Exit(1)
 	}
 
-	// 2. Remove the environment file
+	// 2. Environments extending this one would no longer resolve
+	if dependents := dependentEnvironments(envName); len(dependents) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: Environment '%s' is extended by %s. Remove them or change what they extend first.\n", envName, strings.Join(dependents, ", "))
+		os.Exit(1)
+	}
+
+	// 3. Remove the environment file and its registry entry.
+	// Its history is kept, so it can be restored with rollback.
+	recordHistoryBaseline(envHistory, envName)
 	if err := os.Remove(envFilePath); err != nil {
 		fmt.Fprintf(os.Stderr, "Error removing environment file '%s': %v\n", envFilePath, err)
 		os.Exit(1)
 	}
+	wasDefault := reg.Active == envName
+	reg.Remove(envName)
+	if err := reg.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating environment registry: %v\n", err)
+		os.Exit(1)
+	}
+	recordHistory(envHistory, envName, "remove")
 
 	fmt.Printf("Successfully removed environment '%s'.\n", envName)
 
-	// 3. Check if the removed environment was the active one
-	activeEnv := os.Getenv("CC_PROVIDER_ACTIVE_ENV")
-	if activeEnv == envName {
-		if err := deactivateActiveEnv(); err != nil {
+	// 4. Check if the removed environment was the default or is active in this shell
+	if wasDefault {
+		if _, err := deactivateActiveEnv(envName); err != nil {
 			fmt.Fprintf(os.Stderr, "Error deactivating environment: %v\n", err)
 			os.Exit(1)
 		}
-
-		shellRCPath := "your_shell_config_file"
-		shell := os.Getenv("SHELL")
-		if strings.Contains(shell, "zsh") {
-			shellRCPath = "~/.zshrc"
-		} else if strings.Contains(shell, "bash") {
-			shellRCPath = "~/.bashrc"
-		}
-
-		fmt.Printf("Deactivated environment '%s'.\n", envName)
-		fmt.Println("Please run the following command to apply changes, or open a new terminal:")
-		fmt.Printf("  source %s\n", shellRCPath)
+		fmt.Printf("Deactivated environment '%s'. No environment will be active in new shell sessions.\n", envName)
 	}
-}
-
-// deactivateActiveEnv clears the active_env.sh script.
-func deactivateActiveEnv() error {
-	var sb strings.Builder
-
-	// Add unset commands for all managed keys
-	sb.WriteString("# Unset previous variables managed by cc-provider\n")
-	for _, key := range envVarKeys {
-		sb.WriteString(fmt.Sprintf("unset %s\n", key))
+	if os.Getenv("CC_PROVIDER_ACTIVE_ENV") == envName {
+		applied, err := applyToShell(newDeactivationScript(envName))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error deactivating environment: %v\n", err)
+			os.Exit(1)
+		}
+		if applied {
+			return
+		}
+		fmt.Println("Please run the following command to clear it from the current shell, or open a new terminal:")
+		fmt.Println("  cc-provider deactivate --local")
 	}
-	sb.WriteString("\n")
-
-	// Set the active environment identifier to empty
-	sb.WriteString(`export CC_PROVIDER_ACTIVE_ENV=""`)
-	sb.WriteString("\n")
-
-	// Write the script to file
-	return os.WriteFile(activeEnvFile, []byte(sb.String()), 0644)
 }
 
 // completeEnvironmentNamesForRemove provides completion for environment names
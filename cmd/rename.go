@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// renameCmd represents the rename command
+var renameCmd = &cobra.Command{
+	Use:   "rename <old-name> <new-name>",
+	Short: "Renames a provider environment.",
+	Long: `Renames a provider environment, keeping its variables and metadata.
+Environments extending it, the default environment and the current shell follow it to its new name.
+Project files that name the environment must be updated by hand.`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeSourceEnvironment,
+	Run:               runRenameCmd,
+}
+
+func runRenameCmd(cmd *cobra.Command, args []string) {
+	oldName, newName := args[0], args[1]
+	mustValidateEnvName(oldName)
+	mustValidateEnvName(newName)
+
+	// Secrets are copied and those of environments extending it may be moved
+	// into the vault, which is opened before the lock is taken
+	needed := vaultNeededFor(oldName, nil, oldName)
+	for _, dependent := range dependentEnvironments(oldName) {
+		needed = needed || vaultNeededFor(dependent, nil, "")
+	}
+	v := mustOpenVaultIf(needed)
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	doc := mustLoadEnvDocument(oldName)
+	mustBeNewEnvironment(newName)
+
+	// 1. Move the environment to its new name. Secrets in the vault are
+	// copied, so a later environment named like the old one cannot change them;
+	// the file then refers to the copies, which the old name can use as well
+	// until the move succeeds. The registry follows only once the file is moved.
+	recordHistoryBaseline(envHistory, oldName)
+	v, copied, err := copyVaultSecrets(v, oldName, newName, doc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error copying secrets in vault: %v\n", err)
+		os.Exit(1)
+	}
+	oldFilePath, newFilePath := environmentPath(oldName), environmentPath(newName)
+	if len(copied) > 0 {
+		if err := writeEnvDocument(oldFilePath, doc); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing environment file '%s': %v\n", oldFilePath, err)
+			os.Exit(1)
+		}
+	}
+	if err := os.Rename(oldFilePath, newFilePath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error renaming environment file '%s': %v\n", oldFilePath, err)
+		os.Exit(1)
+	}
+	if err := updateRegistry(func(r *Registry) { r.Rename(oldName, newName) }); err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating environment registry: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 2. Environments extending it now extend the new name.
+	// The old name keeps its history, so it can still be restored with rollback.
+	for _, dependent := range dependentEnvironments(oldName) {
+		dependentDoc := mustLoadEnvDocument(dependent)
+		dependentDoc.Set(extendsKey, newName)
+		mustSaveEnvironment(v, dependent, dependentDoc, nil)
+	}
+	recordHistory(envHistory, oldName, "rename to "+newName)
+	recordHistory(envHistory, newName, "rename from "+oldName)
+
+	// 3. Remove the old vault entries. The history of the old name refers to
+	// the copies instead, so its revisions can still be restored.
+	if len(copied) > 0 {
+		err := rewriteHistoryValues(envHistory, oldName, func(key, value string) (string, bool) {
+			if value != vaultRefPrefix+vaultEntryName(oldName, key) {
+				return value, false
+			}
+			return vaultRefPrefix + vaultEntryName(newName, key), true
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: updating history of '%s': %v\n", oldName, err)
+		}
+		for _, name := range copied {
+			v.Delete(name)
+		}
+		if err := v.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing old secrets from vault: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("Successfully renamed environment '%s' to '%s'.\n", oldName, newName)
+
+	// 4. The current shell follows the environment to its new name, which
+	// refreshEnvironment then activates again
+	if os.Getenv("CC_PROVIDER_ACTIVE_ENV") == oldName {
+		os.Setenv("CC_PROVIDER_ACTIVE_ENV", newName)
+	}
+	if err := refreshEnvironment(newName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying changes: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// mustBeNewEnvironment exits with an error if envName already exists.
+func mustBeNewEnvironment(envName string) {
+	if _, err := os.Stat(environmentPath(envName)); !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error: Environment '%s' already exists.\n", envName)
+		os.Exit(1)
+	}
+}
+
+// completeSourceEnvironment completes the environment to rename or clone; the
+// new name is left to the user.
+func completeSourceEnvironment(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return getEnvironmentNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(renameCmd)
+}
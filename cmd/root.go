@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"os"
-	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -11,10 +10,24 @@ var (
 	// cfgDir is the configuration directory for cc-provider.
 	cfgDir string
 
+	// configDirFlag is the value of the global --config-dir flag.
+	configDirFlag string
+
+	// envsDir holds one file per environment.
+	envsDir string
+
+	// shellDir holds the generated shell scripts.
+	shellDir string
+
+	// stateDir holds the registry, the vault and other state.
+	stateDir string
+
 	// activeEnvFile is the path to the script that holds the active environment variables.
 	activeEnvFile string
 
-	// envVarKeys holds all the environment variable keys that cc-provider manages.
+	// envVarKeys holds the well-known environment variable keys. Environments may
+	// set any other variable too; these are listed first and unset on activation
+	// when no activation recorded the keys it set.
 	envVarKeys = []string{
 		"ANTHROPIC_BASE_URL",
 		"ANTHROPIC_AUTH_TOKEN",
@@ -28,6 +41,22 @@ var (
 		"CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC",
 		"CC_PROVIDER_ACTIVE_ENV",
 	}
+
+	// recommendedEnvKeys are the model settings prompted for by create and modify.
+	recommendedEnvKeys = []string{
+		"ANTHROPIC_MODEL",
+		"ANTHROPIC_DEFAULT_HAIKU_MODEL",
+		"ANTHROPIC_DEFAULT_SONNET_MODEL",
+		"ANTHROPIC_DEFAULT_OPUS_MODEL",
+		"CLAUDE_CODE_SUBAGENT_MODEL",
+		"CLAUDE_CODE_EFFORT_LEVEL",
+	}
+
+	// optionalEnvDefaults are the optional settings and the values used when left empty.
+	optionalEnvDefaults = []EnvVar{
+		{Key: "API_TIMEOUT_MS", Value: "600000"},
+		{Key: "CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC", Value: "1"},
+	}
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -48,24 +77,20 @@ func Execute() {
 	}
 }
 
+func init() {
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		mustValidateOutputFormat()
+		Init(cmd)
+	}
+	rootCmd.PersistentFlags().StringVar(&configDirFlag, "config-dir", "", "Configuration directory (default: $CC_PROVIDER_HOME, $XDG_CONFIG_HOME/cc-provider or ~/.cc-provider)")
+}
+
 // getEnvironmentNames returns a list of available environment names for completion
 // 返回可用环境名称列表用于补全
 func getEnvironmentNames() []string {
-	files, err := os.ReadDir(cfgDir)
+	reg, err := loadRegistry()
 	if err != nil {
 		return []string{}
 	}
-
-	var envs []string
-	for _, file := range files {
-		fileName := file.Name()
-		// 过滤掉系统文件 / Filter out system files
-		if !file.IsDir() &&
-			fileName != "active_env.sh" &&
-			!strings.HasPrefix(fileName, "completion.") &&
-			fileName != "shell_function.sh" {
-			envs = append(envs, fileName)
-		}
-	}
-	return envs
+	return reg.Names()
 }
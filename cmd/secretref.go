@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Secret references let an environment value point at where the secret is
+// kept instead of holding it. They are resolved at activation time:
+//
+//	cmd:<command>   output of the command, run with sh -c
+//	file:<path>     contents of the file (~ is expanded)
+//	env:<NAME>      value of an environment variable
+//	vault:<entry>   entry of the encrypted vault
+//
+// 密钥引用在激活时解析,而不是把密钥复制到配置目录中。
+const (
+	cmdRefPrefix  = "cmd:"
+	fileRefPrefix = "file:"
+	envRefPrefix  = "env:"
+)
+
+// isSecretRef reports whether value is a reference rather than a literal secret.
+func isSecretRef(value string) bool {
+	for _, prefix := range []string{cmdRefPrefix, fileRefPrefix, envRefPrefix, vaultRefPrefix} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretResolver resolves references, unlocking the vault at most once.
+type secretResolver struct {
+	vault *Vault
+}
+
+// resolve returns the value a reference points at. Literal values are returned unchanged.
+func (r *secretResolver) resolve(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, cmdRefPrefix):
+		return resolveCmdRef(strings.TrimPrefix(value, cmdRefPrefix))
+	case strings.HasPrefix(value, fileRefPrefix):
+		return resolveFileRef(strings.TrimPrefix(value, fileRefPrefix))
+	case strings.HasPrefix(value, envRefPrefix):
+		return resolveEnvRef(strings.TrimPrefix(value, envRefPrefix))
+	case isVaultRef(value):
+		if r.vault == nil {
+			v, err := openVault()
+			if err != nil {
+				return "", fmt.Errorf("unlocking vault: %w", err)
+			}
+			r.vault = v
+		}
+		name := strings.TrimPrefix(value, vaultRefPrefix)
+		secret, ok := r.vault.Get(name)
+		if !ok {
+			return "", fmt.Errorf("vault entry '%s' not found", name)
+		}
+		return secret, nil
+	}
+	return value, nil
+}
+
+// resolveVars replaces secret references with their values.
+// 解析密钥引用
+func resolveVars(vars []EnvVar) ([]EnvVar, error) {
+	var r secretResolver
+	resolved := make([]EnvVar, 0, len(vars))
+	for _, ev := range vars {
+		value, err := r.resolve(ev.Value)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s (%s): %w", ev.Key, ev.Value, err)
+		}
+		resolved = append(resolved, EnvVar{Key: ev.Key, Value: value})
+	}
+	return resolved, nil
+}
+
+// resolveCmdRef runs command with sh -c and returns its output without the
+// trailing newline. The command shares the terminal so tools such as pass
+// can ask for a passphrase, unless prompts are disabled.
+func resolveCmdRef(command string) (string, error) {
+	if strings.TrimSpace(command) == "" {
+		return "", fmt.Errorf("empty command")
+	}
+
+	var stdout bytes.Buffer
+	c := exec.Command("sh", "-c", command)
+	if !promptsDisabled {
+		c.Stdin = os.Stdin
+	}
+	c.Stdout = &stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("command failed: %w", err)
+	}
+
+	value := strings.TrimRight(stdout.String(), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("command produced no output")
+	}
+	return value, nil
+}
+
+// resolveFileRef reads the secret from path, expanding a leading ~.
+func resolveFileRef(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("finding home directory: %w", err)
+		}
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// resolveEnvRef reads the secret from an environment variable of the calling process.
+func resolveEnvRef(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// setCmd sets single variables of an environment without going through the
+// prompts of modify.
+var setCmd = &cobra.Command{
+	Use:   "set <env-name> KEY=VALUE [KEY=VALUE...]",
+	Short: "Sets variables of a provider environment.",
+	Long: `Sets one or more variables of a provider environment, keeping everything else in the file.
+If the current shell or the default environment uses it, the change is applied right away.
+
+  cc-provider set deepseek ANTHROPIC_MODEL=deepseek-chat CLAUDE_CODE_EFFORT_LEVEL=high`,
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeSetArgs,
+	Run:               runSetCmd,
+}
+
+func runSetCmd(cmd *cobra.Command, args []string) {
+	envName := args[0]
+	assignments := mustParseAssignments(args[1:])
+	mustValidateEnvName(envName)
+	v := mustOpenVaultIf(vaultNeededFor(envName, assignments, ""))
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	doc := mustLoadEnvDocument(envName)
+	for _, v := range assignments {
+		doc.Set(v.Key, v.Value)
+	}
+	mustHaveRequiredKeys(envName, doc)
+	mustSaveEnvironment(v, envName, doc, nil)
+
+	keys := make([]string, len(assignments))
+	for i, v := range assignments {
+		keys[i] = v.Key
+	}
+	fmt.Printf("Set %s in environment '%s'.\n", strings.Join(keys, ", "), envName)
+
+	if err := refreshEnvironment(envName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying changes: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// mustLoadEnvDocument validates envName and reads its environment file, or
+// exits with an error.
+func mustLoadEnvDocument(envName string) *EnvFile {
+	mustValidateEnvName(envName)
+	if !mustLoadRegistry().Has(envName) {
+		fmt.Fprintf(os.Stderr, "Error: Environment '%s' not found.\n", envName)
+		os.Exit(1)
+	}
+
+	envFilePath := environmentPath(envName)
+	doc, err := readEnvDocument(envFilePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading environment file '%s': %v\n", envFilePath, err)
+		os.Exit(1)
+	}
+	migrateEnvDocument(doc)
+	return doc
+}
+
+// completeSetArgs completes the environment name, then KEY= for the keys of
+// the environment and the well-known keys.
+func completeSetArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return getEnvironmentNames(), cobra.ShellCompDirectiveNoFileComp
+	}
+	if strings.Contains(toComplete, "=") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	keys := environmentKeys(args[0], false)
+	for _, key := range envVarKeys {
+		if !slices.Contains(keys, key) && !slices.Contains(stateKeys(), key) {
+			keys = append(keys, key)
+		}
+	}
+	completions := make([]string, len(keys))
+	for i, key := range keys {
+		completions[i] = key + "="
+	}
+	return completions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+// environmentKeys returns the keys set in the file of envName, or with
+// resolved also the keys it inherits. Errors give no keys.
+func environmentKeys(envName string, resolved bool) []string {
+	if validateEnvName(envName) != nil {
+		return nil
+	}
+	var vars []EnvVar
+	if resolved {
+		env, err := resolveEnvironment(envName)
+		if err != nil {
+			return nil
+		}
+		vars = env.vars
+	} else {
+		doc, err := readEnvDocument(environmentPath(envName))
+		if err != nil {
+			return nil
+		}
+		vars = doc.Vars()
+	}
+
+	var keys []string
+	for _, v := range vars {
+		keys = append(keys, v.Key)
+	}
+	return keys
+}
+
+func init() {
+	rootCmd.AddCommand(setCmd)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Settings are the user preferences kept in config.json in the config directory.
+// 用户设置,保存在配置目录的 config.json 中
+type Settings struct {
+	// ActivateScope is what a plain activate or deactivate changes (see activationScope).
+	ActivateScope activationScope `json:"activateScope,omitempty"`
+	// ProjectHook enables the shell hook that switches environments based on
+	// .cc-provider project files.
+	ProjectHook bool `json:"projectHook,omitempty"`
+	// NoShellSetup stops commands from installing the shell integration. It
+	// is set by uninstall and cleared by setup.
+	NoShellSetup bool `json:"noShellSetup,omitempty"`
+}
+
+// activationScope is what an activation changes: the current shell, the
+// default environment that new shells start with, or both.
+type activationScope string
+
+const (
+	scopeBoth   activationScope = "both"
+	scopeLocal  activationScope = "local"
+	scopeGlobal activationScope = "global"
+)
+
+// activationScopes are the valid scopes.
+var activationScopes = []activationScope{scopeBoth, scopeLocal, scopeGlobal}
+
+// parseActivationScope validates s as a scope.
+func parseActivationScope(s string) (activationScope, error) {
+	for _, scope := range activationScopes {
+		if string(scope) == s {
+			return scope, nil
+		}
+	}
+	return "", fmt.Errorf("invalid scope '%s' (valid: both, local, global)", s)
+}
+
+// resolveActivationScope returns the scope chosen by the --local and --global
+// flags, or the configured scope if neither is given.
+func resolveActivationScope(local, global bool) (activationScope, error) {
+	switch {
+	case local && global:
+		return "", fmt.Errorf("--local and --global cannot be used together")
+	case local:
+		return scopeLocal, nil
+	case global:
+		return scopeGlobal, nil
+	}
+
+	s, err := loadSettings()
+	if err != nil {
+		return "", err
+	}
+	if s.ActivateScope == "" {
+		return scopeBoth, nil
+	}
+	return parseActivationScope(string(s.ActivateScope))
+}
+
+// settingsPath returns the path of the settings file.
+func settingsPath() string {
+	return filepath.Join(cfgDir, "config.json")
+}
+
+// loadSettings reads the settings. Missing settings are the defaults.
+func loadSettings() (*Settings, error) {
+	var s Settings
+	data, err := os.ReadFile(settingsPath())
+	if os.IsNotExist(err) {
+		return &s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading settings: %w", err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", settingsPath(), err)
+	}
+	return &s, nil
+}
+
+// save writes the settings to disk.
+func (s *Settings) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding settings: %w", err)
+	}
+	if err := writeFileAtomic(settingsPath(), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing settings: %w", err)
+	}
+	return nil
+}
+
+// projectHookEnabled reports whether the project hook is enabled. Unreadable
+// settings are reported and leave the hook disabled.
+func projectHookEnabled() bool {
+	s, err := loadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return false
+	}
+	return s.ProjectHook
+}
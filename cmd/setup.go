@@ -3,19 +3,67 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	setupHook      bool // 启用项目钩子 / Enable the project hook
+	setupNoHook    bool // 禁用项目钩子 / Disable the project hook
+	setupDryRun    bool // 只显示差异 / Only show the changes
+	setupUninstall bool // 卸载 shell 集成 / Remove the shell integration
+)
+
 var setupCmd = &cobra.Command{
 	Use:   "setup",
 	Short: "Re-run the shell integration setup.",
 	Long: `Re-run the shell integration setup to ensure shell functions and completion are properly installed.
-This is useful after upgrading cc-provider or if the shell integration is not working.`,
-	Run: runSetupCmd,
+This is useful after upgrading cc-provider or if the shell integration is not working.
+
+The lines added to your shell configuration file are kept in a block between
+'# >>> cc-provider >>>' and '# <<< cc-provider <<<', which is updated in place.
+Use --dry-run to see the changes without making them, and --uninstall (or
+'cc-provider uninstall') to remove them.
+
+Use --hook to also install a hook that switches environments automatically when you
+cd into or out of a directory tree with a .cc-provider file (bash, zsh and fish).`,
+	Annotations: map[string]string{skipShellSetupAnnotation: "true"},
+	Run:         runSetupCmd,
 }
 
 func runSetupCmd(cmd *cobra.Command, args []string) {
+	if setupUninstall {
+		runUninstallCmd(cmd, args)
+		return
+	}
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	if setupDryRun {
+		shellConfigDryRun = true
+		if _, err := ensureShellConfig(true); err != nil {
+			fmt.Fprintf(os.Stderr, "Error during shell setup: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	settings := mustLoadSettings()
+	if setupHook || setupNoHook {
+		settings.ProjectHook = setupHook
+		if setupHook && !projectHookSupported() {
+			fmt.Fprintf(os.Stderr, "Warning: The project hook is only available for bash, zsh and fish.\n")
+		}
+	}
+	settings.NoShellSetup = false
+	if err := settings.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving settings: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Force re-run the shell configuration setup
 	rcPath, err := ensureShellConfig(true)
 	if err != nil {
@@ -26,8 +74,12 @@ func runSetupCmd(cmd *cobra.Command, args []string) {
 	if rcPath != "" {
 		fmt.Printf("Shell integration setup complete.\n")
 		fmt.Printf("Configuration updated in '%s'.\n", rcPath)
+		sourceCommand := "source"
+		if strings.HasSuffix(rcPath, ".ps1") {
+			sourceCommand = "." // PowerShell dot-sourcing
+		}
 		fmt.Println("\nPlease restart your shell or run:")
-		fmt.Printf("  source %s\n", rcPath)
+		fmt.Printf("  %s %s\n", sourceCommand, rcPath)
 	} else {
 		fmt.Println("Shell integration setup complete.")
 		fmt.Println("Your shell is not automatically supported, but you can manually source:")
@@ -35,6 +87,18 @@ func runSetupCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
+// projectHookSupported reports whether the project hook is available for the current shell.
+func projectHookSupported() bool {
+	shell := filepath.Base(os.Getenv("SHELL"))
+	return strings.Contains(shell, "bash") || strings.Contains(shell, "zsh") || strings.Contains(shell, "fish")
+}
+
 func init() {
 	rootCmd.AddCommand(setupCmd)
+	setupCmd.Flags().BoolVar(&setupHook, "hook", false, "Enable automatic activation from .cc-provider project files")
+	setupCmd.Flags().BoolVar(&setupNoHook, "no-hook", false, "Disable automatic activation from .cc-provider project files")
+	setupCmd.Flags().BoolVar(&setupDryRun, "dry-run", false, "Show the changes to your shell configuration without making them")
+	setupCmd.Flags().BoolVar(&setupUninstall, "uninstall", false, "Remove the shell integration (same as 'cc-provider uninstall')")
+	setupCmd.MarkFlagsMutuallyExclusive("hook", "no-hook", "dry-run")
+	setupCmd.MarkFlagsMutuallyExclusive("hook", "no-hook", "uninstall")
 }
@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// envKeyPattern matches the variable names that every supported shell accepts.
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// isValidEnvKey reports whether key is a valid environment variable name.
+func isValidEnvKey(key string) bool {
+	return envKeyPattern.MatchString(key)
+}
+
+// shellQuote quotes s for POSIX shells. The result is a single-quoted word in
+// which nothing is expanded; embedded single quotes are closed, escaped and reopened.
+// 为 POSIX shell 安全地引用字符串
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// managedKeysEnv records the keys the last activation set, space separated,
+// so the next activation unsets exactly those and custom keys never carry over.
+const managedKeysEnv = "CC_PROVIDER_MANAGED_KEYS"
+
+// activationScript describes the shell code needed to switch environments.
+// A script without envName deactivates: it only unsets the managed keys.
+type activationScript struct {
+	envName string
+	unset   []string
+	vars    []EnvVar
+	// deferred are the keys of vars that hold a secret reference. The file
+	// rendering resolves them when it is sourced, so the secret is never
+	// written to disk; eval output always holds resolved values.
+	deferred []string
+	message  string // printed to stderr by the eval output
+}
+
+// newActivationScript builds the activation for envName from the decoded variables.
+// Invalid variable names are rejected so they can never reach the shell.
+func newActivationScript(envName string, vars []EnvVar) (*activationScript, error) {
+	script := &activationScript{
+		envName: envName,
+		unset:   managedKeys(),
+		message: fmt.Sprintf("Environment '%s' activated.", envName),
+	}
+	for _, v := range vars {
+		if !isValidEnvKey(v.Key) {
+			return nil, fmt.Errorf("invalid environment variable name %q", v.Key)
+		}
+		if slices.Contains(stateKeys(), v.Key) {
+			continue
+		}
+		script.vars = append(script.vars, v)
+	}
+	return script, nil
+}
+
+// newDeactivationScript builds the script that clears the managed keys.
+// previous is the environment being deactivated, if known.
+func newDeactivationScript(previous string) *activationScript {
+	message := "No environment was active."
+	if previous != "" {
+		message = fmt.Sprintf("Environment '%s' deactivated.", previous)
+	}
+	return &activationScript{unset: managedKeys(), message: message}
+}
+
+// managedKeys returns the keys an activation in this process unsets: the keys
+// recorded by the previous activation and the state kept by cc-provider.
+func managedKeys() []string {
+	keys := previousKeys()
+	for _, key := range stateKeys() {
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// previousKeys returns the keys set by the previous activation, as recorded
+// in managedKeysEnv. Without a record, as after activations by older
+// versions, the well-known keys in envVarKeys are assumed.
+func previousKeys() []string {
+	recorded, ok := os.LookupEnv(managedKeysEnv)
+	if !ok {
+		return slices.Clone(envVarKeys)
+	}
+	var keys []string
+	for _, key := range strings.Fields(recorded) {
+		if isValidEnvKey(key) && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// fallbackKeys are the keys unset when no activation recorded its keys.
+func fallbackKeys() []string {
+	var keys []string
+	for _, key := range envVarKeys {
+		if !slices.Contains(stateKeys(), key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// stateKeys are the variables cc-provider itself keeps in the shell. They are
+// never taken from environment files.
+func stateKeys() []string {
+	return append([]string{"CC_PROVIDER_ACTIVE_ENV", managedKeysEnv}, projectStateKeys...)
+}
+
+// recordedKeys returns the value of managedKeysEnv for the script: the keys
+// it sets, other than the state keys.
+func (s *activationScript) recordedKeys() string {
+	var keys []string
+	for _, v := range s.vars {
+		if !slices.Contains(stateKeys(), v.Key) {
+			keys = append(keys, v.Key)
+		}
+	}
+	return strings.Join(keys, " ")
+}
+
+// environ returns base with the activation applied: the keys it unsets are
+// removed and its variables are added. The directives file of the calling
+// shell function is removed too, so the process cannot change that shell.
+func (s *activationScript) environ(base []string) []string {
+	drop := map[string]bool{directivesEnv: true, directivesShellEnv: true}
+	for _, key := range s.unset {
+		drop[key] = true
+	}
+	for _, v := range s.vars {
+		drop[v.Key] = true
+	}
+
+	var env []string
+	for _, kv := range base {
+		key, _, _ := strings.Cut(kv, "=")
+		if !drop[key] {
+			env = append(env, kv)
+		}
+	}
+	for _, v := range s.vars {
+		env = append(env, v.Key+"="+v.Value)
+	}
+	if s.envName != "" {
+		env = append(env, "CC_PROVIDER_ACTIVE_ENV="+s.envName, managedKeysEnv+"="+s.recordedKeys())
+	}
+	return env
+}
+
+// isDeferred reports whether the file rendering resolves key when it is sourced.
+func (s *activationScript) isDeferred(key string) bool {
+	return slices.Contains(s.deferred, key)
+}
+
+// resolveArgs are the arguments of the cc-provider command that prints the
+// resolved value of key in envName, which the active environment scripts run
+// for deferred variables.
+func resolveArgs(envName, key string) []string {
+	return []string{"--config-dir", cfgDir, "get", envName, key, "--reveal"}
+}
+
+// setVar sets key to value, replacing an earlier value of key.
+func (s *activationScript) setVar(key, value string) {
+	for i := range s.vars {
+		if s.vars[i].Key == key {
+			s.vars[i].Value = value
+			return
+		}
+	}
+	s.vars = append(s.vars, EnvVar{Key: key, Value: value})
+}
+
+// shellDialect renders activation scripts for one family of shells.
+// 每种 shell 方言负责生成对应语法的激活脚本
+type shellDialect interface {
+	// scriptExt is the extension of the active environment script of the dialect.
+	scriptExt() string
+	// file renders the script sourced by new shells at startup.
+	file(s *activationScript) string
+	// eval renders the output of activate --eval, which the shell wrapper applies.
+	eval(s *activationScript) string
+}
+
+// shellDialects maps the names accepted by --shell to their dialect.
+var shellDialects = map[string]shellDialect{
+	"sh":         posixDialect,
+	"bash":       posixDialect,
+	"zsh":        posixDialect,
+	"fish":       fishDialect,
+	"pwsh":       pwshDialect,
+	"powershell": pwshDialect,
+	"nu":         nuDialect,
+	"nushell":    nuDialect,
+}
+
+// activeScriptDialects are the dialects an active environment script is kept
+// for, so new shells of every kind pick up the active environment.
+var activeScriptDialects = []shellDialect{posixDialect, fishDialect, pwshDialect, nuDialect}
+
+// quoteEach quotes every arg with quote and joins them with spaces.
+func quoteEach(args []string, quote func(string) string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellDialectNames returns the sorted names accepted by --shell.
+func shellDialectNames() []string {
+	names := make([]string, 0, len(shellDialects))
+	for name := range shellDialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// activeEnvScriptPath returns the path of the active environment script of d.
+func activeEnvScriptPath(d shellDialect) string {
+	return filepath.Join(shellDir, "active_env"+d.scriptExt())
+}
+
+// commandDialect is a dialect that activates with one command per variable.
+type commandDialect struct {
+	ext   string
+	unset func(key string) string
+	set   func(key, value string) string
+	// setResolved renders code that sets key to the output of cc-provider run with args.
+	setResolved func(key string, args []string) string
+	echoErr     func(msg string) string
+	// unsetRecorded renders code that unsets the keys listed in
+	// managedKeysEnv, or the fallback keys if it is not set.
+	unsetRecorded func(fallback []string) string
+	// guard, if set, is the first line of the file; it stops sourcing the
+	// file inside a shell started by 'cc-provider shell'.
+	guard string
+}
+
+func (d commandDialect) scriptExt() string { return d.ext }
+
+// file renders one command per line, starting by unsetting all managed keys
+// to ensure a clean state. The keys set by the previous activation are only
+// known when the file is sourced, so they are read from managedKeysEnv then.
+func (d commandDialect) file(s *activationScript) string {
+	var sb strings.Builder
+
+	if d.guard != "" {
+		sb.WriteString("# Keep the environment of 'cc-provider shell'\n")
+		sb.WriteString(d.guard + "\n\n")
+	}
+
+	sb.WriteString("# Unset previous variables managed by cc-provider\n")
+	sb.WriteString(d.unsetRecorded(fallbackKeys()) + "\n")
+	for _, key := range stateKeys() {
+		sb.WriteString(d.unset(key) + "\n")
+	}
+	sb.WriteString("\n")
+
+	if s.envName != "" {
+		sb.WriteString(fmt.Sprintf("# Export variables for environment: %s\n", strings.ReplaceAll(s.envName, "\n", " ")))
+		for _, v := range s.vars {
+			if s.isDeferred(v.Key) {
+				sb.WriteString(d.setResolved(v.Key, resolveArgs(s.envName, v.Key)) + "\n")
+				continue
+			}
+			sb.WriteString(d.set(v.Key, v.Value) + "\n")
+		}
+		sb.WriteString("\n")
+
+		// Set the active environment identifier and record the keys set
+		sb.WriteString(d.set("CC_PROVIDER_ACTIVE_ENV", s.envName) + "\n")
+		sb.WriteString(d.set(managedKeysEnv, s.recordedKeys()) + "\n")
+	}
+	return sb.String()
+}
+
+// eval renders the same commands on one line, followed by a message on
+// stderr so it doesn't interfere with eval.
+func (d commandDialect) eval(s *activationScript) string {
+	var sb strings.Builder
+	for _, key := range s.unset {
+		sb.WriteString(d.unset(key) + "; ")
+	}
+	for _, v := range s.vars {
+		sb.WriteString(d.set(v.Key, v.Value) + "; ")
+	}
+	if s.envName != "" {
+		sb.WriteString(d.set("CC_PROVIDER_ACTIVE_ENV", s.envName) + "; ")
+		sb.WriteString(d.set(managedKeysEnv, s.recordedKeys()) + "; ")
+	}
+	sb.WriteString(d.echoErr(s.message) + "\n")
+	return sb.String()
+}
+
+// posixDialect is used by sh, bash and zsh.
+var posixDialect = commandDialect{
+	ext:   ".sh",
+	unset: func(key string) string { return "unset " + key },
+	set:   func(key, value string) string { return fmt.Sprintf("export %s=%s", key, shellQuote(value)) },
+	setResolved: func(key string, args []string) string {
+		return fmt.Sprintf(`export %s="$(command cc-provider %s)"`, key, quoteEach(args, shellQuote))
+	},
+	echoErr: func(msg string) string { return fmt.Sprintf("echo %s >&2", shellQuote(msg)) },
+	unsetRecorded: func(fallback []string) string {
+		// Command substitution is split into words by both bash and zsh. The
+		// loop variable is namespaced and removed, so no variable of the
+		// user is touched.
+		return fmt.Sprintf(`if [ -n "${%[1]s+x}" ]; then
+    for __cc_provider_key in $(echo "$%[1]s"); do unset "$__cc_provider_key"; done
+    unset __cc_provider_key
+else
+    unset %[2]s
+fi`, managedKeysEnv, strings.Join(fallback, " "))
+	},
+	guard: `[ -n "${` + subshellEnv + `-}" ] && return`,
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenConfigDir is the config directory the deferred variables are resolved in.
+const goldenConfigDir = "/home/user/.cc-provider"
+
+// goldenActivation has values every dialect has to quote: quotes, $, a
+// backtick and a newline, a recorded list of keys to unset, and a secret
+// reference the file rendering resolves when it is sourced.
+var goldenActivation = &activationScript{
+	envName: "client's-env",
+	unset:   []string{"ANTHROPIC_MODEL", "OLD_CUSTOM_KEY", "CC_PROVIDER_ACTIVE_ENV", managedKeysEnv},
+	vars: []EnvVar{
+		{Key: "ANTHROPIC_BASE_URL", Value: "https://api.example.com/$(id)"},
+		{Key: "ANTHROPIC_AUTH_TOKEN", Value: `sk-'single' "double" $HOME ${PATH} ` + "`whoami`"},
+		{Key: "ANTHROPIC_MODEL", Value: "line one\nline two"},
+		{Key: "CUSTOM_HEADER", Value: `back\slash 'a'# r#'raw'#`},
+		{Key: "ANTHROPIC_API_KEY", Value: "vault:client's-env/ANTHROPIC_API_KEY"},
+	},
+	deferred: []string{"ANTHROPIC_API_KEY"},
+	message:  "Environment 'client's-env' activated.",
+}
+
+// goldenDeactivation clears the recorded keys without setting any.
+var goldenDeactivation = &activationScript{
+	unset:   []string{"ANTHROPIC_BASE_URL", "CUSTOM_HEADER", "CC_PROVIDER_ACTIVE_ENV", managedKeysEnv},
+	message: "Environment 'client's-env' deactivated.",
+}
+
+func TestDialectGolden(t *testing.T) {
+	setConfigDir(t, goldenConfigDir)
+	dialects := map[string]shellDialect{
+		"posix": posixDialect,
+		"fish":  fishDialect,
+		"pwsh":  pwshDialect,
+		"nu":    nuDialect,
+	}
+	scripts := map[string]*activationScript{
+		"activate":   goldenActivation,
+		"deactivate": goldenDeactivation,
+	}
+
+	for dialectName, dialect := range dialects {
+		for scriptName, script := range scripts {
+			outputs := map[string]string{
+				"file": dialect.file(script),
+				"eval": dialect.eval(script),
+			}
+			for kind, got := range outputs {
+				name := dialectName + "_" + scriptName + "_" + kind
+				t.Run(name, func(t *testing.T) {
+					checkGolden(t, filepath.Join("testdata", name+".golden"), got)
+				})
+			}
+		}
+	}
+}
+
+// TestPosixEvalRoundTrip checks that bash sets every value exactly as given.
+func TestPosixEvalRoundTrip(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	script := posixDialect.eval(goldenActivation)
+	for _, v := range goldenActivation.vars {
+		out, err := exec.Command(bash, "-c", script+`printf '%s' "$`+v.Key+`"`).Output()
+		if err != nil {
+			t.Fatalf("running the eval output: %v", err)
+		}
+		if string(out) != v.Value {
+			t.Errorf("%s = %q, want %q", v.Key, out, v.Value)
+		}
+	}
+}
+
+// TestPosixFileResolvesDeferred checks that sourcing the file asks
+// cc-provider for the deferred variables and sets the others as given.
+func TestPosixFileResolvesDeferred(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	setConfigDir(t, goldenConfigDir)
+
+	// A stand-in for cc-provider that prints its arguments
+	bin := t.TempDir()
+	stub := "#!/bin/sh\nprintf '%s|' \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "cc-provider"), []byte(stub), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "active_env.sh")
+	if err := os.WriteFile(path, []byte(posixDialect.file(goldenActivation)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"ANTHROPIC_API_KEY": "--config-dir|" + goldenConfigDir + "|get|client's-env|ANTHROPIC_API_KEY|--reveal|",
+		"CUSTOM_HEADER":     `back\slash 'a'# r#'raw'#`,
+	}
+	for key, value := range want {
+		c := exec.Command(bash, "-c", `. "$1"; printf '%s' "$`+key+`"`, "bash", path)
+		c.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+		out, err := c.Output()
+		if err != nil {
+			t.Fatalf("sourcing the file: %v", err)
+		}
+		if string(out) != value {
+			t.Errorf("%s = %q, want %q", key, out, value)
+		}
+	}
+}
+
+// setConfigDir points the config directory at dir for the duration of t.
+func setConfigDir(t *testing.T, dir string) {
+	saved := cfgDir
+	cfgDir = dir
+	t.Cleanup(func() { cfgDir = saved })
+}
+
+// checkGolden compares got with the golden file at path, or rewrites the
+// file when the tests run with -update.
+func checkGolden(t *testing.T, path, got string) {
+	t.Helper()
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run go test ./cmd -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s (run go test ./cmd -update to accept it)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
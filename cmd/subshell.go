@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// subshellEnv is set to the environment of a shell started by 'cc-provider
+// shell'. The active environment scripts leave such a shell alone.
+const subshellEnv = "CC_PROVIDER_SUBSHELL"
+
+var subshellCmd = &cobra.Command{
+	Use:   "shell <env-name>",
+	Short: "Starts a shell with a provider environment applied.",
+	Long: `Starts an interactive $SHELL with the variables of a provider environment, without
+activating it: other terminals and new shells keep the active environment. The prompt
+is prefixed with the environment name (bash, zsh, fish and PowerShell). Type 'exit' to
+leave the shell.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	Run:               runSubshellCmd,
+}
+
+func runSubshellCmd(cmd *cobra.Command, args []string) {
+	envName := args[0]
+	script := mustLoadEnvironmentScript(envName)
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	path, err := exec.LookPath(shell)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	unlock := mustLockConfig()
+	argv, extraEnv, err := subshellArgs(path, envName)
+	unlock()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error preparing shell: %v\n", err)
+		os.Exit(1)
+	}
+
+	env := append(script.environ(os.Environ()), subshellEnv+"="+envName)
+	env = append(env, extraEnv...)
+
+	fmt.Fprintf(os.Stderr, "Starting %s with environment '%s'. Type 'exit' to leave.\n", filepath.Base(path), envName)
+	if err := execProcess(path, argv, env); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting shell: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// subshellArgs returns the arguments and extra variables that start the shell
+// at path with its usual configuration and a prompt marked with envName. The
+// startup files doing so are written to the shell directory.
+// 返回启动子 shell 的参数,加载用户配置并在提示符中标记环境
+func subshellArgs(path, envName string) ([]string, []string, error) {
+	switch base := filepath.Base(path); {
+	case strings.Contains(base, "bash"):
+		rcPath := filepath.Join(shellDir, "subshell.bash")
+		_, err := writeFileIfChanged(rcPath, []byte(bashSubshellRC), 0644)
+		return []string{path, "--rcfile", rcPath, "-i"}, nil, err
+
+	case strings.Contains(base, "zsh"):
+		// zsh has no --rcfile; its startup files are found through ZDOTDIR
+		zdotdir := filepath.Join(shellDir, "subshell-zsh")
+		if err := os.MkdirAll(zdotdir, 0755); err != nil {
+			return nil, nil, err
+		}
+		_, err := writeFileIfChanged(filepath.Join(zdotdir, ".zshenv"), []byte(zshSubshellEnv), 0644)
+		env := []string{"ZDOTDIR=" + zdotdir}
+		if original, ok := os.LookupEnv("ZDOTDIR"); ok {
+			env = append(env, "CC_PROVIDER_ZDOTDIR="+original)
+		}
+		return []string{path, "-i"}, env, err
+
+	case strings.Contains(base, "fish"):
+		rcPath := filepath.Join(shellDir, "subshell.fish")
+		_, err := writeFileIfChanged(rcPath, []byte(fishSubshellRC), 0644)
+		return []string{path, "-i", "-C", "source " + fishQuote(rcPath)}, nil, err
+
+	case strings.Contains(base, "pwsh") || strings.Contains(base, "powershell"):
+		rcPath := filepath.Join(shellDir, "subshell.ps1")
+		_, err := writeFileIfChanged(rcPath, []byte(pwshSubshellRC), 0644)
+		return []string{path, "-NoExit", "-Command", ". " + pwshQuote(rcPath)}, nil, err
+
+	case base == "nu" || base == "nushell":
+		// config.nu sources active_env.nu unconditionally, so the environment would be lost
+		return nil, nil, fmt.Errorf("nushell is not supported, use 'cc-provider exec %s -- nu' instead", envName)
+
+	default:
+		ps1 := os.Getenv("PS1")
+		if ps1 == "" {
+			ps1 = "$ "
+		}
+		return []string{path, "-i"}, []string{"PS1=(" + envName + ") " + ps1}, nil
+	}
+}
+
+// bashSubshellRC is the --rcfile of bash started by 'cc-provider shell'.
+const bashSubshellRC = `# Started by 'cc-provider shell': load the usual configuration, then mark the prompt
+if [ -f ~/.bashrc ]; then . ~/.bashrc; fi
+PS1="(${CC_PROVIDER_SUBSHELL}) ${PS1-}"
+`
+
+// zshSubshellEnv is the .zshenv of zsh started by 'cc-provider shell'. It
+// restores ZDOTDIR, so the user's own startup files are read from then on,
+// and marks the prompt before each prompt is shown, since themes often
+// rebuild it.
+const zshSubshellEnv = `# Started by 'cc-provider shell': restore ZDOTDIR, load the usual configuration
+# and mark the prompt
+if [ -n "${CC_PROVIDER_ZDOTDIR+x}" ]; then ZDOTDIR=$CC_PROVIDER_ZDOTDIR; else unset ZDOTDIR; fi
+unset CC_PROVIDER_ZDOTDIR
+if [ -f "${ZDOTDIR:-$HOME}/.zshenv" ]; then . "${ZDOTDIR:-$HOME}/.zshenv"; fi
+
+if [[ -o interactive ]]; then
+    _cc_provider_subshell_prompt() {
+        [[ $PROMPT == "(${CC_PROVIDER_SUBSHELL}) "* ]] || PROMPT="(${CC_PROVIDER_SUBSHELL}) $PROMPT"
+    }
+    autoload -Uz add-zsh-hook
+    add-zsh-hook precmd _cc_provider_subshell_prompt
+fi
+`
+
+// fishSubshellRC is run by fish started by 'cc-provider shell' after its configuration.
+const fishSubshellRC = `# Started by 'cc-provider shell': mark the prompt
+functions -q fish_prompt; and functions -c fish_prompt __cc_provider_subshell_prompt
+function fish_prompt
+    echo -n "($CC_PROVIDER_SUBSHELL) "
+    functions -q __cc_provider_subshell_prompt; and __cc_provider_subshell_prompt
+end
+`
+
+// pwshSubshellRC is run by PowerShell started by 'cc-provider shell' after its profile.
+const pwshSubshellRC = `# Started by 'cc-provider shell': mark the prompt
+$global:__ccProviderPrompt = $function:prompt
+function global:prompt { "($env:CC_PROVIDER_SUBSHELL) " + (& $global:__ccProviderPrompt) }
+`
+
+func init() {
+	rootCmd.AddCommand(subshellCmd)
+}
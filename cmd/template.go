@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 )
 
 // Template represents a provider configuration template
@@ -56,9 +58,6 @@ var builtInTemplates = map[string]Template{
 
 // initTemplateDir initializes the template directory
 func initTemplateDir() error {
-	if templateDir == "" {
-		templateDir = filepath.Join(cfgDir, "templates")
-	}
 	if err := os.MkdirAll(templateDir, 0755); err != nil {
 		return fmt.Errorf("failed to create template directory: %w", err)
 	}
@@ -87,6 +86,11 @@ func getTemplate(name string) (*Template, error) {
 	if err := json.Unmarshal(data, &tmpl); err != nil {
 		return nil, fmt.Errorf("failed to parse template '%s': %w", name, err)
 	}
+	for key, value := range tmpl.EnvVars {
+		if err := validateEnvValue(value); err != nil {
+			return nil, fmt.Errorf("template '%s': %s: %w", name, key, err)
+		}
+	}
 
 	return &tmpl, nil
 }
@@ -104,11 +108,8 @@ func listTemplates() ([]Template, error) {
 		templates = append(templates, tmpl)
 	}
 
-	// Add custom templates
-	entries, err := os.ReadDir(templateDir)
-	if err != nil {
-		return templates, nil
-	}
+	// Add custom templates; an unreadable directory only hides them
+	entries, _ := os.ReadDir(templateDir)
 
 	for _, entry := range entries {
 		if entry.IsDir() {
@@ -132,6 +133,7 @@ func listTemplates() ([]Template, error) {
 		templates = append(templates, tmpl)
 	}
 
+	slices.SortFunc(templates, func(a, b Template) int { return strings.Compare(a.Name, b.Name) })
 	return templates, nil
 }
 
@@ -147,7 +149,7 @@ func saveCustomTemplate(tmpl Template) error {
 		return fmt.Errorf("failed to marshal template: %w", err)
 	}
 
-	if err := os.WriteFile(templatePath, data, 0644); err != nil {
+	if err := writeFileAtomic(templatePath, data, 0600); err != nil {
 		return fmt.Errorf("failed to save template: %w", err)
 	}
 
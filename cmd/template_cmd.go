@@ -16,17 +16,45 @@ var templateCmd = &cobra.Command{
 }
 
 var templateListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List all available templates.",
-	Long:  `List all built-in and custom provider configuration templates.`,
-	Run:   runTemplateListCmd,
+	Use:         "list",
+	Short:       "List all available templates.",
+	Long:        `List all built-in and custom provider configuration templates.`,
+	Annotations: map[string]string{readOnlyAnnotation: "true"},
+	Run:         runTemplateListCmd,
 }
 
 var templateAddCmd = &cobra.Command{
-	Use:   "add",
+	Use:   "add [template-name]",
 	Short: "Add a custom template.",
-	Long:  `Interactively create a new custom provider configuration template.`,
-	Run:   runTemplateAddCmd,
+	Long: `Interactively create a new custom provider configuration template.
+
+With --set, --yes or without a terminal, nothing is asked and the template holds
+the variables given with --set:
+
+  cc-provider template add gateway --description "Company gateway" --set ANTHROPIC_BASE_URL=https://...`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runTemplateAddCmd,
+}
+
+var (
+	templateAddDescription string   // 模板描述 / Template description
+	templateAddSet         []string // KEY=VALUE 形式的变量 / Variables as KEY=VALUE
+	templateAddYes         bool     // 不提示 / Do not prompt
+)
+
+// templateKeys are the variables template add prompts for.
+var templateKeys = []string{
+	"ANTHROPIC_BASE_URL",
+	"ANTHROPIC_AUTH_TOKEN",
+	"ANTHROPIC_MODEL",
+	"ANTHROPIC_SMALL_FAST_MODEL",
+	"ANTHROPIC_DEFAULT_HAIKU_MODEL",
+	"ANTHROPIC_DEFAULT_SONNET_MODEL",
+	"ANTHROPIC_DEFAULT_OPUS_MODEL",
+	"CLAUDE_CODE_SUBAGENT_MODEL",
+	"CLAUDE_CODE_EFFORT_LEVEL",
+	"API_TIMEOUT_MS",
+	"CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC",
 }
 
 var templateRemoveCmd = &cobra.Command{
@@ -38,11 +66,12 @@ var templateRemoveCmd = &cobra.Command{
 }
 
 var templateShowCmd = &cobra.Command{
-	Use:   "show [template-name]",
-	Short: "Show template details.",
-	Long:  `Display the details of a specific template.`,
-	Args:  cobra.ExactArgs(1),
-	Run:   runTemplateShowCmd,
+	Use:         "show [template-name]",
+	Short:       "Show template details.",
+	Long:        `Display the details of a specific template.`,
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{readOnlyAnnotation: "true"},
+	Run:         runTemplateShowCmd,
 }
 
 func runTemplateListCmd(cmd *cobra.Command, args []string) {
@@ -52,6 +81,15 @@ func runTemplateListCmd(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	if structuredOutput() {
+		list := templateList{Templates: []templateInfo{}}
+		for i := range templates {
+			list.Templates = append(list.Templates, newTemplateInfo(&templates[i]))
+		}
+		printStructured(list)
+		return
+	}
+
 	if len(templates) == 0 {
 		fmt.Println("No templates available.")
 		return
@@ -69,9 +107,21 @@ func runTemplateListCmd(cmd *cobra.Command, args []string) {
 
 func runTemplateAddCmd(cmd *cobra.Command, args []string) {
 	reader := bufio.NewReader(os.Stdin)
+	interactive := shouldPrompt(templateAddYes, len(templateAddSet) > 0)
+	assignments := mustParseAssignments(templateAddSet)
 
 	// Get template name
-	name := prompt(reader, "Enter template name", true)
+	var name string
+	switch {
+	case len(args) > 0:
+		name = args[0]
+	case interactive:
+		name = prompt(reader, "Enter template name", true)
+	default:
+		fmt.Fprintln(os.Stderr, "Error: A template name is required: cc-provider template add <template-name>")
+		os.Exit(1)
+	}
+	mustValidateTemplateName(name)
 	if _, ok := builtInTemplates[name]; ok {
 		fmt.Fprintf(os.Stderr, "Error: Template name '%s' conflicts with built-in template.\n", name)
 		os.Exit(1)
@@ -85,65 +135,27 @@ func runTemplateAddCmd(cmd *cobra.Command, args []string) {
 	}
 
 	// Get description
-	description := prompt(reader, "Enter template description", true)
-
-	// Get environment variables
-	envVars := make(map[string]string)
-	fmt.Println("\nEnter environment variables (press Enter to skip):")
-
-	baseURL := prompt(reader, "  ANTHROPIC_BASE_URL", false)
-	if baseURL != "" {
-		envVars["ANTHROPIC_BASE_URL"] = baseURL
-	}
-
-	authToken := prompt(reader, "  ANTHROPIC_AUTH_TOKEN", false)
-	if authToken != "" {
-		envVars["ANTHROPIC_AUTH_TOKEN"] = authToken
-	}
-
-	model := prompt(reader, "  ANTHROPIC_MODEL", false)
-	if model != "" {
-		envVars["ANTHROPIC_MODEL"] = model
-	}
-
-	smallModel := prompt(reader, "  ANTHROPIC_SMALL_FAST_MODEL", false)
-	if smallModel != "" {
-		envVars["ANTHROPIC_SMALL_FAST_MODEL"] = smallModel
-	}
-
-	haikuModel := prompt(reader, "  ANTHROPIC_DEFAULT_HAIKU_MODEL", false)
-	if haikuModel != "" {
-		envVars["ANTHROPIC_DEFAULT_HAIKU_MODEL"] = haikuModel
+	description := templateAddDescription
+	if description == "" && interactive {
+		description = prompt(reader, "Enter template description", true)
 	}
-
-	sonnetModel := prompt(reader, "  ANTHROPIC_DEFAULT_SONNET_MODEL", false)
-	if sonnetModel != "" {
-		envVars["ANTHROPIC_DEFAULT_SONNET_MODEL"] = sonnetModel
-	}
-
-	opusModel := prompt(reader, "  ANTHROPIC_DEFAULT_OPUS_MODEL", false)
-	if opusModel != "" {
-		envVars["ANTHROPIC_DEFAULT_OPUS_MODEL"] = opusModel
-	}
-
-	subagentModel := prompt(reader, "  CLAUDE_CODE_SUBAGENT_MODEL", false)
-	if subagentModel != "" {
-		envVars["CLAUDE_CODE_SUBAGENT_MODEL"] = subagentModel
-	}
-
-	effortLevel := prompt(reader, "  CLAUDE_CODE_EFFORT_LEVEL", false)
-	if effortLevel != "" {
-		envVars["CLAUDE_CODE_EFFORT_LEVEL"] = effortLevel
+	if description == "" {
+		fmt.Fprintln(os.Stderr, "Error: A description is required: --description <text>")
+		os.Exit(1)
 	}
 
-	timeout := prompt(reader, "  API_TIMEOUT_MS", false)
-	if timeout != "" {
-		envVars["API_TIMEOUT_MS"] = timeout
+	// Get environment variables
+	envVars := make(map[string]string)
+	for _, v := range assignments {
+		envVars[v.Key] = v.Value
 	}
-
-	disableTraffic := prompt(reader, "  CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC", false)
-	if disableTraffic != "" {
-		envVars["CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC"] = disableTraffic
+	if interactive {
+		fmt.Println("\nEnter environment variables (press Enter to skip):")
+		for _, key := range templateKeys {
+			if value := prompt(reader, "  "+key, false); value != "" {
+				envVars[key] = value
+			}
+		}
 	}
 
 	// Create template
@@ -153,10 +165,26 @@ func runTemplateAddCmd(cmd *cobra.Command, args []string) {
 		EnvVars:     envVars,
 	}
 
+	// Keep secrets in the vault if one has been initialized
+	doc := NewEnvFile()
+	for key, value := range envVars {
+		doc.Set(key, value)
+	}
+	v := mustOpenVaultIf(vaultNeeded(doc, ""))
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	if _, _, err := storeTemplateSecretsInVault(v, &newTemplate); err != nil {
+		fmt.Fprintf(os.Stderr, "Error storing secrets in vault: %v\n", err)
+		os.Exit(1)
+	}
+
 	if err := saveCustomTemplate(newTemplate); err != nil {
 		fmt.Fprintf(os.Stderr, "Error saving template: %v\n", err)
 		os.Exit(1)
 	}
+	recordHistory(templateHistory, name, "create")
 
 	fmt.Printf("\nSuccessfully created template '%s'.\n", name)
 	fmt.Printf("You can now use this template when creating a new environment.\n")
@@ -164,22 +192,29 @@ func runTemplateAddCmd(cmd *cobra.Command, args []string) {
 
 func runTemplateRemoveCmd(cmd *cobra.Command, args []string) {
 	name := args[0]
+	mustValidateTemplateName(name)
 
 	if _, ok := builtInTemplates[name]; ok {
 		fmt.Fprintf(os.Stderr, "Error: Cannot remove built-in template '%s'.\n", name)
 		os.Exit(1)
 	}
 
+	unlock := mustLockConfig()
+	defer unlock()
+
+	recordHistoryBaseline(templateHistory, name)
 	if err := deleteCustomTemplate(name); err != nil {
 		fmt.Fprintf(os.Stderr, "Error removing template: %v\n", err)
 		os.Exit(1)
 	}
+	recordHistory(templateHistory, name, "remove")
 
 	fmt.Printf("Successfully removed template '%s'.\n", name)
 }
 
 func runTemplateShowCmd(cmd *cobra.Command, args []string) {
 	name := args[0]
+	mustValidateTemplateName(name)
 
 	tmpl, err := getTemplate(name)
 	if err != nil {
@@ -187,19 +222,22 @@ func runTemplateShowCmd(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	info := newTemplateInfo(tmpl)
+	if structuredOutput() {
+		printStructured(info)
+		return
+	}
+
 	isBuiltIn := ""
-	if _, ok := builtInTemplates[name]; ok {
+	if info.Source == "built-in" {
 		isBuiltIn = " (built-in)"
 	}
 
 	fmt.Printf("Template: %s%s\n", tmpl.Name, isBuiltIn)
 	fmt.Printf("Description: %s\n", tmpl.Description)
 	fmt.Println("\nEnvironment variables:")
-	for key, value := range tmpl.EnvVars {
-		if key == "ANTHROPIC_AUTH_TOKEN" {
-			value = maskSecret(value)
-		}
-		fmt.Printf("  %s=\"%s\"\n", key, value)
+	for _, v := range info.Variables {
+		fmt.Printf("  %s=\"%s\"\n", v.Key, v.Value)
 	}
 }
 
@@ -213,7 +251,7 @@ func completeTemplateNames(cmd *cobra.Command, args []string, toComplete string)
 	for _, tmpl := range templates {
 		names = append(names, tmpl.Name)
 	}
-	return names, cobra.ShellCompDirectiveNoFileComp
+	return validNames(names, validateTemplateName), cobra.ShellCompDirectiveNoFileComp
 }
 
 func init() {
@@ -223,6 +261,10 @@ func init() {
 	templateCmd.AddCommand(templateRemoveCmd)
 	templateCmd.AddCommand(templateShowCmd)
 
+	templateAddCmd.Flags().StringVar(&templateAddDescription, "description", "", "Description of the template")
+	templateAddCmd.Flags().StringArrayVar(&templateAddSet, "set", nil, "Set a variable, as KEY=VALUE (repeatable)")
+	templateAddCmd.Flags().BoolVarP(&templateAddYes, "yes", "y", false, "Do not prompt")
+
 	templateRemoveCmd.ValidArgsFunction = completeTemplateNames
 	templateShowCmd.ValidArgsFunction = completeTemplateNames
 }
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	uninstallPurge  bool // 同时删除配置目录 / Also delete the config directory
+	uninstallYes    bool // 不再确认 / Do not ask for confirmation
+	uninstallDryRun bool // 只显示将要进行的更改 / Only show what would change
+)
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Removes the shell integration.",
+	Long: `Removes the cc-provider blocks from your shell configuration files (bash, zsh,
+PowerShell and nushell), the fish conf.d snippet and the generated scripts.
+Your environments are kept, and commands no longer install the shell integration
+until you run 'cc-provider setup' again.
+
+Use --purge to also delete the config directory with all environments, templates,
+history and the vault.`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{skipShellSetupAnnotation: "true"},
+	Run:         runUninstallCmd,
+}
+
+func runUninstallCmd(cmd *cobra.Command, args []string) {
+	dryRun := uninstallDryRun || setupDryRun
+	shellConfigDryRun = dryRun
+
+	if uninstallPurge && !uninstallYes && !dryRun {
+		fmt.Printf("This deletes '%s' with all environments, templates, history and the vault.\n", cfgDir)
+		reader := bufio.NewReader(os.Stdin)
+		if answer := prompt(reader, "Type 'yes' to continue", false); !strings.EqualFold(answer, "yes") {
+			fmt.Println("Aborted.")
+			os.Exit(1)
+		}
+	}
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	// 1. Remove the managed blocks from the shell configuration files
+	for _, rcPath := range shellConfigFiles() {
+		content, err := os.ReadFile(rcPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", rcPath, err)
+			os.Exit(1)
+		}
+		updated, err := removeManagedBlock(string(content))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", rcPath, err)
+			os.Exit(1)
+		}
+		changed, err := writeShellConfigFile(rcPath, []byte(updated))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if changed && !dryRun {
+			fmt.Printf("Removed cc-provider configuration from '%s'.\n", rcPath)
+		}
+	}
+
+	// 2. Remove the files cc-provider owns
+	toRemove := []string{shellDir}
+	if home, err := os.UserHomeDir(); err == nil {
+		toRemove = append(toRemove, filepath.Join(fishConfDir(home), "cc-provider.fish"))
+	}
+	if uninstallPurge {
+		toRemove = append(toRemove, cfgDir)
+	}
+	for _, path := range toRemove {
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("Would remove '%s'.\n", path)
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing '%s': %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed '%s'.\n", path)
+	}
+	if dryRun {
+		return
+	}
+
+	// 3. Keep later commands from installing the integration again
+	if !uninstallPurge {
+		settings := mustLoadSettings()
+		settings.NoShellSetup = true
+		if err := settings.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving settings: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Println("\ncc-provider has been uninstalled. Restart your shell to apply changes.")
+	if !uninstallPurge {
+		fmt.Printf("Your environments are kept in '%s'. Run 'cc-provider setup' to install the shell integration again.\n", cfgDir)
+	}
+}
+
+// shellConfigFiles returns the shell configuration files cc-provider may have edited.
+func shellConfigFiles() []string {
+	var files []string
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".bashrc"), filepath.Join(home, ".zshrc"), pwshProfilePath(home))
+	}
+	if nuConfig, err := nuConfigPath(); err == nil {
+		files = append(files, nuConfig)
+	}
+	return files
+}
+
+func init() {
+	rootCmd.AddCommand(uninstallCmd)
+	uninstallCmd.Flags().BoolVar(&uninstallPurge, "purge", false, "Also delete the config directory with all environments")
+	uninstallCmd.Flags().BoolVarP(&uninstallYes, "yes", "y", false, "Do not ask for confirmation")
+	uninstallCmd.Flags().BoolVar(&uninstallDryRun, "dry-run", false, "Show what would be removed without removing it")
+}
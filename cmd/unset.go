@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// unsetCmd removes single variables from an environment.
+var unsetCmd = &cobra.Command{
+	Use:   "unset <env-name> KEY [KEY...]",
+	Short: "Removes variables from a provider environment.",
+	Long: `Removes one or more variables from a provider environment, keeping everything else in the file.
+A removed variable that the environment extends from its base takes the inherited value again.
+If the current shell or the default environment uses it, the change is applied right away.`,
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeUnsetArgs,
+	Run:               runUnsetCmd,
+}
+
+func runUnsetCmd(cmd *cobra.Command, args []string) {
+	envName, keys := args[0], args[1:]
+	mustValidateEnvName(envName)
+	v := mustOpenVaultIf(vaultNeededFor(envName, nil, ""))
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	doc := mustLoadEnvDocument(envName)
+	for _, key := range keys {
+		if !doc.Unset(key) {
+			fmt.Fprintf(os.Stderr, "Error: %s is not set in environment '%s'.\n", key, envName)
+			os.Exit(1)
+		}
+	}
+	mustHaveRequiredKeys(envName, doc)
+	mustSaveEnvironment(v, envName, doc, nil)
+
+	fmt.Printf("Removed %s from environment '%s'.\n", strings.Join(keys, ", "), envName)
+
+	if err := refreshEnvironment(envName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying changes: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// completeUnsetArgs completes the environment name, then the keys set in it.
+func completeUnsetArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return getEnvironmentNames(), cobra.ShellCompDirectiveNoFileComp
+	}
+	return environmentKeys(args[0], false), cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(unsetCmd)
+}
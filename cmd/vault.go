@@ -0,0 +1,582 @@
+package cmd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// vaultRefPrefix marks an env value that is stored in the vault.
+	vaultRefPrefix = "vault:"
+
+	// vaultPassphraseEnv lets scripts unlock the vault without a terminal.
+	vaultPassphraseEnv = "CC_PROVIDER_VAULT_PASSPHRASE"
+
+	// vaultNewPassphraseEnv supplies the new passphrase to 'vault rekey'.
+	vaultNewPassphraseEnv = "CC_PROVIDER_VAULT_NEW_PASSPHRASE"
+
+	// vaultSessionEnv holds the secret that unwraps the key in the session
+	// file. 'vault unlock' sets it in the shell it was run from only.
+	vaultSessionEnv = "CC_PROVIDER_VAULT_SESSION"
+
+	vaultVersion    = 1
+	vaultKDFName    = "pbkdf2-sha256"
+	vaultIterations = 600000
+	vaultKeyLength  = 32
+	vaultSaltLength = 16
+)
+
+// vaultAAD binds the ciphertext to this file format.
+var vaultAAD = []byte("cc-provider-vault-v1")
+
+// errVaultNotInitialized is returned when an operation needs a vault that does not exist.
+var errVaultNotInitialized = errors.New("vault is not initialized; run 'cc-provider vault init' first")
+
+// promptsDisabled is set by commands that must never wait for input, such as
+// the hook run on every cd. Secrets that need a passphrase or a terminal then
+// fail to resolve instead.
+var promptsDisabled bool
+
+// openedVaultKey is the key of the vault once this process has opened it, so
+// the vault is opened again without asking for the passphrase.
+var openedVaultKey []byte
+
+// vaultKDF holds the key derivation parameters stored alongside the ciphertext.
+type vaultKDF struct {
+	Name       string `json:"name"`
+	Salt       []byte `json:"salt"`
+	Iterations int    `json:"iterations"`
+}
+
+// vaultFile is the on-disk format of the vault.
+type vaultFile struct {
+	Version    int      `json:"version"`
+	KDF        vaultKDF `json:"kdf"`
+	Nonce      []byte   `json:"nonce"`
+	Ciphertext []byte   `json:"ciphertext"`
+}
+
+// vaultSession caches the derived key after 'vault unlock' until it expires.
+// The key is encrypted with a random secret that is never written to disk,
+// so the session file alone does not unlock the vault.
+type vaultSession struct {
+	WrappedKey []byte    `json:"wrappedKey"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	// LegacyKey is the raw key stored by older versions.
+	LegacyKey []byte `json:"key,omitempty"`
+}
+
+// Vault is an unlocked credential vault.
+// Vault 是已解锁的凭据保险库
+type Vault struct {
+	file    vaultFile
+	key     []byte
+	entries map[string]string
+}
+
+// vaultPath returns the path of the encrypted vault file.
+func vaultPath() string {
+	return filepath.Join(stateDir, "vault.json")
+}
+
+// vaultSessionPath returns the path of the session file written by 'vault unlock'.
+func vaultSessionPath() string {
+	return filepath.Join(stateDir, "vault.session")
+}
+
+// vaultExists reports whether a vault has been initialized.
+func vaultExists() bool {
+	_, err := os.Stat(vaultPath())
+	return err == nil
+}
+
+// isVaultRef reports whether value references a vault entry.
+func isVaultRef(value string) bool {
+	return strings.HasPrefix(value, vaultRefPrefix)
+}
+
+// vaultEntryName returns the entry name used for a variable of an environment.
+func vaultEntryName(envName, key string) string {
+	return envName + "/" + key
+}
+
+// deriveVaultKey derives the encryption key from a passphrase.
+func deriveVaultKey(passphrase string, kdf vaultKDF) ([]byte, error) {
+	if kdf.Name != vaultKDFName {
+		return nil, fmt.Errorf("unsupported key derivation function '%s'", kdf.Name)
+	}
+	return pbkdf2.Key(sha256.New, passphrase, kdf.Salt, kdf.Iterations, vaultKeyLength)
+}
+
+// newVaultKDF returns fresh key derivation parameters with a random salt.
+func newVaultKDF() (vaultKDF, error) {
+	salt := make([]byte, vaultSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return vaultKDF{}, fmt.Errorf("generating salt: %w", err)
+	}
+	return vaultKDF{Name: vaultKDFName, Salt: salt, Iterations: vaultIterations}, nil
+}
+
+// initVault creates a new, empty vault locked with passphrase.
+func initVault(passphrase string) (*Vault, error) {
+	kdf, err := newVaultKDF()
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveVaultKey(passphrase, kdf)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Vault{
+		file:    vaultFile{Version: vaultVersion, KDF: kdf},
+		key:     key,
+		entries: make(map[string]string),
+	}
+	return v, v.save()
+}
+
+// readVaultFile loads the encrypted vault from disk.
+func readVaultFile() (vaultFile, error) {
+	var vf vaultFile
+	data, err := os.ReadFile(vaultPath())
+	if os.IsNotExist(err) {
+		return vf, errVaultNotInitialized
+	}
+	if err != nil {
+		return vf, fmt.Errorf("reading vault: %w", err)
+	}
+	if err := json.Unmarshal(data, &vf); err != nil {
+		return vf, fmt.Errorf("parsing vault: %w", err)
+	}
+	if vf.Version != vaultVersion {
+		return vf, fmt.Errorf("unsupported vault version %d", vf.Version)
+	}
+	return vf, nil
+}
+
+// decryptVault opens the vault file with key.
+func decryptVault(vf vaultFile, key []byte) (*Vault, error) {
+	aead, err := newVaultAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, vf.Nonce, vf.Ciphertext, vaultAAD)
+	if err != nil {
+		return nil, errors.New("incorrect passphrase or corrupted vault")
+	}
+
+	entries := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return nil, fmt.Errorf("parsing vault contents: %w", err)
+	}
+	return &Vault{file: vf, key: key, entries: entries}, nil
+}
+
+// unlockVaultWithPassphrase opens the vault using passphrase.
+func unlockVaultWithPassphrase(passphrase string) (*Vault, error) {
+	vf, err := readVaultFile()
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveVaultKey(passphrase, vf.KDF)
+	if err != nil {
+		return nil, err
+	}
+	return decryptVault(vf, key)
+}
+
+// openVault unlocks the vault on demand. It uses the session left by
+// 'vault unlock' if there is a valid one, then CC_PROVIDER_VAULT_PASSPHRASE,
+// and finally prompts for the passphrase on the terminal.
+// 按需解锁保险库
+func openVault() (*Vault, error) {
+	vf, err := readVaultFile()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cached := range []func() ([]byte, error){
+		func() ([]byte, error) { return openedVaultKey, nil },
+		readVaultSession,
+	} {
+		if key, err := cached(); err == nil && key != nil {
+			if v, err := decryptVault(vf, key); err == nil {
+				openedVaultKey = key
+				return v, nil
+			}
+		}
+	}
+
+	passphrase, err := readPassphrase(vaultPassphraseEnv, "Vault passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveVaultKey(passphrase, vf.KDF)
+	if err != nil {
+		return nil, err
+	}
+	v, err := decryptVault(vf, key)
+	if err != nil {
+		return nil, err
+	}
+	openedVaultKey = key
+	return v, nil
+}
+
+// vaultNeeded reports whether saving doc needs the unlocked vault: it holds
+// plaintext secrets to move into the vault or, if copyFrom is set, references
+// to the vault entries of copyFrom to copy.
+func vaultNeeded(doc *EnvFile, copyFrom string) bool {
+	if !vaultExists() {
+		return false
+	}
+	for _, ev := range doc.Vars() {
+		if isSecretKey(ev.Key) && ev.Value != "" && !isSecretRef(ev.Value) {
+			return true
+		}
+		if copyFrom != "" && ev.Value == vaultRefPrefix+vaultEntryName(copyFrom, ev.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+// vaultNeededFor is vaultNeeded for the environment envName, as its file
+// reads now, with changes applied.
+func vaultNeededFor(envName string, changes []EnvVar, copyFrom string) bool {
+	doc, err := readEnvDocument(environmentPath(envName))
+	if err != nil {
+		doc = NewEnvFile()
+	}
+	for _, v := range changes {
+		doc.Set(v.Key, v.Value)
+	}
+	return vaultNeeded(doc, copyFrom)
+}
+
+// mustOpenVaultIf opens the vault if needed is set, and returns nil otherwise.
+// Commands call it before they take the config lock, so the passphrase is
+// never asked while other commands wait for the lock.
+// 在加锁之前解锁保险库,避免持锁等待输入口令
+func mustOpenVaultIf(needed bool) *Vault {
+	if !needed {
+		return nil
+	}
+	v, err := openVault()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error unlocking vault: %v\n", err)
+		os.Exit(1)
+	}
+	return v
+}
+
+// reload reads the vault file again, so that saving v keeps what other
+// commands stored since v was opened. The caller must hold the config lock.
+func (v *Vault) reload() error {
+	vf, err := readVaultFile()
+	if err != nil {
+		return err
+	}
+	fresh, err := decryptVault(vf, v.key)
+	if err != nil {
+		return err
+	}
+	*v = *fresh
+	return nil
+}
+
+// save encrypts the entries with a fresh nonce and writes the vault file.
+func (v *Vault) save() error {
+	aead, err := newVaultAEAD(v.key)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(v.entries)
+	if err != nil {
+		return fmt.Errorf("encoding vault contents: %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generating nonce: %w", err)
+	}
+	v.file.Nonce = nonce
+	v.file.Ciphertext = aead.Seal(nil, nonce, plaintext, vaultAAD)
+
+	data, err := json.MarshalIndent(v.file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding vault: %w", err)
+	}
+	if err := writeFileAtomic(vaultPath(), data, 0600); err != nil {
+		return fmt.Errorf("writing vault: %w", err)
+	}
+	return nil
+}
+
+// rekey re-encrypts the vault under a new passphrase and salt.
+func (v *Vault) rekey(passphrase string) error {
+	kdf, err := newVaultKDF()
+	if err != nil {
+		return err
+	}
+	key, err := deriveVaultKey(passphrase, kdf)
+	if err != nil {
+		return err
+	}
+	v.file.KDF = kdf
+	v.key = key
+	return v.save()
+}
+
+// Get returns the secret stored under name.
+func (v *Vault) Get(name string) (string, bool) {
+	value, ok := v.entries[name]
+	return value, ok
+}
+
+// Delete removes the secret stored under name. Call save to persist it.
+func (v *Vault) Delete(name string) {
+	delete(v.entries, name)
+}
+
+// Put stores a secret under name. Call save to persist it.
+func (v *Vault) Put(name, value string) {
+	v.entries[name] = value
+}
+
+// newVaultAEAD returns the AES-256-GCM cipher for key.
+func newVaultAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// readVaultSession returns the cached key if the session has not expired and
+// vaultSessionEnv holds the secret it was wrapped with.
+func readVaultSession() ([]byte, error) {
+	data, err := os.ReadFile(vaultSessionPath())
+	if err != nil {
+		return nil, err
+	}
+	var session vaultSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	if session.LegacyKey != nil || time.Now().After(session.ExpiresAt) {
+		os.Remove(vaultSessionPath())
+		return nil, errors.New("vault session expired")
+	}
+
+	secret, err := base64.RawURLEncoding.DecodeString(os.Getenv(vaultSessionEnv))
+	if err != nil || len(secret) != vaultKeyLength {
+		return nil, errors.New("no vault session in this shell")
+	}
+	aead, err := newVaultAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(session.WrappedKey) < aead.NonceSize() {
+		return nil, errors.New("invalid vault session")
+	}
+	nonce, wrapped := session.WrappedKey[:aead.NonceSize()], session.WrappedKey[aead.NonceSize():]
+	return aead.Open(nil, nonce, wrapped, vaultAAD)
+}
+
+// writeVaultSession caches the vault key until timeout elapses. It returns
+// the secret the key is wrapped with, which belongs in vaultSessionEnv.
+// 缓存的密钥由随机会话密钥加密,会话密钥只保存在 shell 环境变量中
+func writeVaultSession(v *Vault, timeout time.Duration) (string, error) {
+	secret := make([]byte, vaultKeyLength)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("generating session secret: %w", err)
+	}
+	aead, err := newVaultAEAD(secret)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+
+	session := vaultSession{
+		WrappedKey: aead.Seal(nonce, nonce, v.key, vaultAAD),
+		ExpiresAt:  time.Now().Add(timeout),
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(vaultSessionPath(), data, 0600); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// lockVault removes the cached session key.
+func lockVault() error {
+	if err := os.Remove(vaultSessionPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// readPassphrase returns the passphrase from the environment variable envName
+// if it is set, and otherwise reads it without echo. The prompt goes to the
+// terminal so it works even when stdout is captured by eval.
+// 无回显读取口令
+func readPassphrase(envName, message string) (string, error) {
+	if passphrase := os.Getenv(envName); passphrase != "" {
+		return passphrase, nil
+	}
+	// Other commands wait for the config lock, so they must not wait on a person too
+	if configLocked || promptsDisabled {
+		return "", fmt.Errorf("vault is locked; run 'cc-provider vault unlock' or set %s", envName)
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("vault is locked and no terminal is available; run 'cc-provider vault unlock' or set %s", envName)
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, message)
+	passphrase, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase: %w", err)
+	}
+	return string(passphrase), nil
+}
+
+// readNewPassphrase asks for a new passphrase twice and checks they match.
+func readNewPassphrase(envName string) (string, error) {
+	if passphrase := os.Getenv(envName); passphrase != "" {
+		return passphrase, nil
+	}
+
+	passphrase, err := readPassphrase(envName, "New vault passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("passphrase must not be empty")
+	}
+	confirm, err := readPassphrase(envName, "Confirm passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != confirm {
+		return "", errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// storeSecretsInVault moves plaintext secrets of doc into the vault and replaces
+// them with references. It does nothing if no vault has been initialized.
+// v is opened on demand when nil, and read again otherwise; the (possibly
+// opened) vault is returned.
+// 将明文密钥移入保险库并替换为引用
+func storeSecretsInVault(v *Vault, owner string, doc *EnvFile) (*Vault, bool, error) {
+	if !vaultExists() {
+		return v, false, nil
+	}
+	if v != nil {
+		if err := v.reload(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	changed := false
+	for _, ev := range doc.Vars() {
+		if !isSecretKey(ev.Key) || ev.Value == "" || isSecretRef(ev.Value) {
+			continue
+		}
+		if v == nil {
+			var err error
+			if v, err = openVault(); err != nil {
+				return nil, false, err
+			}
+		}
+		name := vaultEntryName(owner, ev.Key)
+		v.Put(name, ev.Value)
+		doc.Set(ev.Key, vaultRefPrefix+name)
+		changed = true
+	}
+
+	if changed {
+		if err := v.save(); err != nil {
+			return nil, false, err
+		}
+	}
+	return v, changed, nil
+}
+
+// copyVaultSecrets makes the references doc holds to the vault entries of
+// from point at copies stored for to, so that storing a new secret for
+// either environment never changes the other. v is opened on demand when
+// nil, and read again otherwise. It returns the vault, if it was opened, and
+// the names of the entries that were copied. It does nothing if doc holds no
+// such reference.
+func copyVaultSecrets(v *Vault, from, to string, doc *EnvFile) (*Vault, []string, error) {
+	if v != nil {
+		if err := v.reload(); err != nil {
+			return nil, nil, err
+		}
+	}
+	var copied []string
+	for _, ev := range doc.Vars() {
+		if ev.Value != vaultRefPrefix+vaultEntryName(from, ev.Key) {
+			continue
+		}
+		if v == nil {
+			var err error
+			if v, err = openVault(); err != nil {
+				return nil, nil, err
+			}
+		}
+		secret, ok := v.Get(vaultEntryName(from, ev.Key))
+		if !ok {
+			continue
+		}
+		v.Put(vaultEntryName(to, ev.Key), secret)
+		doc.Set(ev.Key, vaultRefPrefix+vaultEntryName(to, ev.Key))
+		copied = append(copied, vaultEntryName(from, ev.Key))
+	}
+	if len(copied) == 0 {
+		return v, nil, nil
+	}
+	return v, copied, v.save()
+}
+
+// storeTemplateSecretsInVault moves plaintext secrets of a custom template into
+// the vault. It does nothing if no vault has been initialized.
+func storeTemplateSecretsInVault(v *Vault, tmpl *Template) (*Vault, bool, error) {
+	doc := NewEnvFile()
+	for key, value := range tmpl.EnvVars {
+		doc.Set(key, value)
+	}
+
+	v, changed, err := storeSecretsInVault(v, "templates/"+tmpl.Name, doc)
+	if err != nil || !changed {
+		return v, changed, err
+	}
+	tmpl.EnvVars = doc.Map()
+	return v, true, nil
+}
@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var vaultUnlockTimeout time.Duration
+
+var vaultCmd = &cobra.Command{
+	Use:   "vault",
+	Short: "Manage the encrypted credential vault.",
+	Long: `Manage the optional encrypted vault that stores auth tokens and other secrets.
+
+Once a vault is initialized, environment files reference vault entries
+(e.g. ANTHROPIC_AUTH_TOKEN="vault:deepseek/ANTHROPIC_AUTH_TOKEN") instead of
+holding the raw secret. The vault is unlocked on demand when an environment
+is activated, exported or inspected.`,
+	Run: func(cmd *cobra.Command, args []string) { cmd.Help() },
+}
+
+var vaultInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a new vault protected by a passphrase.",
+	Long: `Create a new, empty vault protected by a passphrase.
+The key is derived with PBKDF2-SHA256 and secrets are encrypted with AES-256-GCM.`,
+	Args: cobra.NoArgs,
+	Run:  runVaultInitCmd,
+}
+
+var vaultUnlockCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Unlock the vault for a limited time.",
+	Long: `Unlock the vault and cache the key so later commands do not prompt for the passphrase until the timeout expires.
+
+The key is cached in state/vault.session, encrypted with a random session secret
+that is only kept in CC_PROVIDER_VAULT_SESSION of the current shell. Commands
+run from that shell, and the processes they start, can use the vault without
+the passphrase until the timeout or 'vault lock'; other shells still prompt.
+Anything that can read both the config directory and the environment of that
+shell, such as other processes of your user, can decrypt the vault meanwhile.
+Without the shell function, the command prints the variable to export.`,
+	Args: cobra.NoArgs,
+	Run:  runVaultUnlockCmd,
+}
+
+var vaultLockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Lock the vault.",
+	Long:  `Forget the cached vault key. The passphrase will be required again on next use.`,
+	Args:  cobra.NoArgs,
+	Run:   runVaultLockCmd,
+}
+
+var vaultRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Change the vault passphrase.",
+	Long:  `Re-encrypt the vault under a new passphrase. Any cached session is discarded.`,
+	Args:  cobra.NoArgs,
+	Run:   runVaultRekeyCmd,
+}
+
+var vaultMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Move plaintext secrets of existing environments into the vault.",
+	Long: `Move plaintext secrets from every environment and custom template into the vault,
+replacing them with vault references.`,
+	Args: cobra.NoArgs,
+	Run:  runVaultMigrateCmd,
+}
+
+func runVaultInitCmd(cmd *cobra.Command, args []string) {
+	if vaultExists() {
+		fmt.Fprintf(os.Stderr, "Error: A vault already exists at '%s'.\n", vaultPath())
+		os.Exit(1)
+	}
+
+	passphrase, err := readNewPassphrase(vaultPassphraseEnv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	if vaultExists() {
+		fmt.Fprintf(os.Stderr, "Error: A vault already exists at '%s'.\n", vaultPath())
+		os.Exit(1)
+	}
+	if _, err := initVault(passphrase); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating vault: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Vault created at '%s'.\n", vaultPath())
+	fmt.Println("New secrets will be stored in the vault. To move existing ones, run: cc-provider vault migrate")
+}
+
+func runVaultUnlockCmd(cmd *cobra.Command, args []string) {
+	passphrase, err := readPassphrase(vaultPassphraseEnv, "Vault passphrase: ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	v, err := unlockVaultWithPassphrase(passphrase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error unlocking vault: %v\n", err)
+		os.Exit(1)
+	}
+
+	secret, err := writeVaultSession(v, vaultUnlockTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving vault session: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The session secret only goes to the shell the command was run from
+	script := &activationScript{
+		vars:    []EnvVar{{Key: vaultSessionEnv, Value: secret}},
+		message: fmt.Sprintf("Vault unlocked for %s.", vaultUnlockTimeout),
+	}
+	applied, err := applyToShell(script)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying vault session: %v\n", err)
+		os.Exit(1)
+	}
+	if !applied {
+		fmt.Printf("Vault unlocked for %s. To use the session in this shell, run:\n", vaultUnlockTimeout)
+		fmt.Printf("  export %s=%s\n", vaultSessionEnv, secret)
+	}
+}
+
+func runVaultLockCmd(cmd *cobra.Command, args []string) {
+	if err := lockVault(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error locking vault: %v\n", err)
+		os.Exit(1)
+	}
+
+	script := &activationScript{unset: []string{vaultSessionEnv}, message: "Vault locked."}
+	applied, err := applyToShell(script)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying vault lock: %v\n", err)
+		os.Exit(1)
+	}
+	if !applied {
+		fmt.Println("Vault locked.")
+	}
+}
+
+func runVaultRekeyCmd(cmd *cobra.Command, args []string) {
+	passphrase, err := readPassphrase(vaultPassphraseEnv, "Current vault passphrase: ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	newPassphrase, err := readNewPassphrase(vaultNewPassphraseEnv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	v, err := unlockVaultWithPassphrase(passphrase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error unlocking vault: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := v.rekey(newPassphrase); err != nil {
+		fmt.Fprintf(os.Stderr, "Error re-encrypting vault: %v\n", err)
+		os.Exit(1)
+	}
+	if err := lockVault(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error clearing vault session: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Vault passphrase changed.")
+}
+
+func runVaultMigrateCmd(cmd *cobra.Command, args []string) {
+	if !vaultExists() {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", errVaultNotInitialized)
+		os.Exit(1)
+	}
+
+	v, err := openVault()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error unlocking vault: %v\n", err)
+		os.Exit(1)
+	}
+
+	unlock := mustLockConfig()
+	defer unlock()
+
+	migrated := 0
+	for _, envName := range getEnvironmentNames() {
+		envFilePath := environmentPath(envName)
+		doc, err := readEnvDocument(envFilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading environment '%s': %v\n", envName, err)
+			os.Exit(1)
+		}
+
+		_, changed, err := storeSecretsInVault(v, envName, doc)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error migrating environment '%s': %v\n", envName, err)
+			os.Exit(1)
+		}
+		if !changed {
+			continue
+		}
+		recordHistoryBaseline(envHistory, envName)
+
+		// The vault is saved before the file is rewritten, so a failure here
+		// leaves the plaintext in place rather than losing the secret.
+		if err := writeEnvDocument(envFilePath, doc); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing environment '%s': %v\n", envName, err)
+			os.Exit(1)
+		}
+		recordHistory(envHistory, envName, "vault migrate")
+		if err := redactHistorySecrets(envHistory, envName); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: removing plaintext secrets from the history of '%s': %v\n", envName, err)
+		}
+		fmt.Printf("  Migrated environment '%s'.\n", envName)
+		migrated++
+	}
+
+	templates, err := listTemplates()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing templates: %v\n", err)
+		os.Exit(1)
+	}
+	for _, tmpl := range templates {
+		if _, ok := builtInTemplates[tmpl.Name]; ok {
+			continue
+		}
+
+		_, changed, err := storeTemplateSecretsInVault(v, &tmpl)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error migrating template '%s': %v\n", tmpl.Name, err)
+			os.Exit(1)
+		}
+		if !changed {
+			continue
+		}
+		recordHistoryBaseline(templateHistory, tmpl.Name)
+		if err := saveCustomTemplate(tmpl); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing template '%s': %v\n", tmpl.Name, err)
+			os.Exit(1)
+		}
+		recordHistory(templateHistory, tmpl.Name, "vault migrate")
+		if err := redactHistorySecrets(templateHistory, tmpl.Name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: removing plaintext secrets from the history of '%s': %v\n", tmpl.Name, err)
+		}
+		fmt.Printf("  Migrated template '%s'.\n", tmpl.Name)
+		migrated++
+	}
+
+	if migrated == 0 {
+		fmt.Println("No plaintext secrets found.")
+		return
+	}
+	fmt.Printf("Moved secrets of %d item(s) into the vault.\n", migrated)
+}
+
+func init() {
+	rootCmd.AddCommand(vaultCmd)
+	vaultCmd.AddCommand(vaultInitCmd)
+	vaultCmd.AddCommand(vaultUnlockCmd)
+	vaultCmd.AddCommand(vaultLockCmd)
+	vaultCmd.AddCommand(vaultRekeyCmd)
+	vaultCmd.AddCommand(vaultMigrateCmd)
+
+	vaultUnlockCmd.Flags().DurationVar(&vaultUnlockTimeout, "timeout", 15*time.Minute, "How long the vault stays unlocked")
+}
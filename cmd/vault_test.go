@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestVaultSessionNeedsSecret checks that the session file alone does not
+// hold the vault key, and that the key comes back with the session secret.
+func TestVaultSessionNeedsSecret(t *testing.T) {
+	saved := stateDir
+	stateDir = t.TempDir()
+	t.Cleanup(func() { stateDir = saved })
+	v := &Vault{key: bytes.Repeat([]byte{0x42}, vaultKeyLength)}
+
+	secret, err := writeVaultSession(v, time.Minute)
+	if err != nil {
+		t.Fatalf("writeVaultSession: %v", err)
+	}
+	data, err := os.ReadFile(vaultSessionPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, v.key) || bytes.Contains(data, []byte("QkJCQkJC")) {
+		t.Fatalf("session file contains the raw key: %s", data)
+	}
+
+	t.Setenv(vaultSessionEnv, "")
+	if _, err := readVaultSession(); err == nil {
+		t.Fatal("readVaultSession without the session secret succeeded")
+	}
+
+	t.Setenv(vaultSessionEnv, secret)
+	key, err := readVaultSession()
+	if err != nil {
+		t.Fatalf("readVaultSession: %v", err)
+	}
+	if !bytes.Equal(key, v.key) {
+		t.Fatalf("readVaultSession = %x, want %x", key, v.key)
+	}
+}
@@ -22,10 +22,11 @@ var (
 
 // versionCmd represents the version command
 var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Display version information.",
-	Long:  `Display the version, build time, and git commit information of cc-provider.`,
-	Run:   runVersionCmd,
+	Use:         "version",
+	Short:       "Display version information.",
+	Long:        `Display the version, build time, and git commit information of cc-provider.`,
+	Annotations: map[string]string{readOnlyAnnotation: "true"},
+	Run:         runVersionCmd,
 }
 
 func runVersionCmd(cmd *cobra.Command, args []string) {
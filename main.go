@@ -4,6 +4,5 @@ import "cc-provider/cmd"
 
 // main is the entry point of the application.
 func main() {
-	cmd.Init()
 	cmd.Execute()
 }